@@ -0,0 +1,186 @@
+package ovpncfg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+// ClientType is an OpenVPN client application with its own quirks.
+type ClientType string
+
+const (
+	ClientGeneric        ClientType = "generic"
+	ClientOpenVPNConnect ClientType = "openvpn-connect"
+	ClientTunnelblick    ClientType = "tunnelblick"
+	ClientNetworkManager ClientType = "networkmanager"
+	ClientOpenWrt        ClientType = "openwrt"
+)
+
+type clientTarget struct {
+	// drop maps directives the client ignores or chokes on to the reason
+	// they're removed.
+	drop map[string]string
+
+	// unsupported maps directives the client can't honor to the reason,
+	// they're kept but reported.
+	unsupported map[string]string
+
+	// adjust applies settings specific to the client and returns extra
+	// warnings.
+	adjust func(config *generator.Config) []string
+}
+
+var scriptsReason = "scripts are not run by this client"
+
+var clientTargets = map[ClientType]clientTarget{
+	ClientGeneric: {},
+	ClientOpenVPNConnect: {
+		drop: map[string]string{
+			"keysize": "not supported by OpenVPN 3",
+		},
+		unsupported: map[string]string{
+			"user":            "OpenVPN Connect does not drop privileges",
+			"group":           "OpenVPN Connect does not drop privileges",
+			"script-security": scriptsReason,
+			"up":              scriptsReason,
+			"down":            scriptsReason,
+			"route-up":        scriptsReason,
+			"management":      "OpenVPN Connect has no management interface",
+			"pkcs12":          "import the PKCS#12 file into OpenVPN Connect instead",
+		},
+		adjust: func(config *generator.Config) []string {
+			// The name OpenVPN Connect shows for the profile.
+			if remote, ok := config.Get("remote"); ok && len(remote) > 0 {
+				config.MustAdd("setenv", "FRIENDLY_NAME", remote[0])
+			}
+
+			_, embedded := config.Embedded("auth-user-pass")
+			if values, ok := config.Get("auth-user-pass"); ok && (len(values) > 0 || embedded) {
+				return []string{"auth-user-pass: OpenVPN Connect ignores the given credentials and asks for them"}
+			}
+			return nil
+		},
+	},
+	ClientTunnelblick: {
+		drop: map[string]string{
+			"user":  "Tunnelblick drops privileges itself, setting a user breaks reconnections",
+			"group": "Tunnelblick drops privileges itself, setting a group breaks reconnections",
+		},
+		unsupported: map[string]string{
+			"management": "Tunnelblick uses the management interface itself",
+			"up":         "Tunnelblick runs its own scripts, add yours to the Tunnelblick configuration",
+			"down":       "Tunnelblick runs its own scripts, add yours to the Tunnelblick configuration",
+		},
+	},
+	ClientNetworkManager: {
+		drop: map[string]string{
+			"keysize":      "ignored by NetworkManager",
+			"resolv-retry": "ignored by NetworkManager, which manages reconnections itself",
+			"persist-key":  "ignored by NetworkManager, which manages reconnections itself",
+			"persist-tun":  "ignored by NetworkManager, which manages reconnections itself",
+		},
+		unsupported: map[string]string{
+			"user":            "NetworkManager runs OpenVPN with its own user",
+			"group":           "NetworkManager runs OpenVPN with its own group",
+			"script-security": scriptsReason,
+			"up":              scriptsReason,
+			"down":            scriptsReason,
+			"route-up":        scriptsReason,
+			"management":      "NetworkManager uses the management interface itself",
+		},
+		adjust: func(config *generator.Config) []string {
+			_, embedded := config.Embedded("auth-user-pass")
+			if values, ok := config.Get("auth-user-pass"); ok && (len(values) > 0 || embedded) {
+				return []string{"auth-user-pass: NetworkManager keeps credentials in its own secret store, the given ones are ignored"}
+			}
+			return nil
+		},
+	},
+	ClientOpenWrt: {
+		drop: map[string]string{
+			"keysize": "not supported by recent OpenVPN builds",
+		},
+		unsupported: map[string]string{
+			"pkcs12":           "OpenVPN starts at boot on OpenWrt, nobody can enter the PKCS#12 password",
+			"static-challenge": "OpenVPN starts at boot on OpenWrt, nobody can enter the one-time code",
+		},
+		adjust: func(config *generator.Config) []string {
+			// OpenVPN starts as root at boot.
+			config.MustSet("user", "nobody")
+			config.MustSet("group", "nogroup")
+
+			_, embedded := config.Embedded("auth-user-pass")
+			if values, ok := config.Get("auth-user-pass"); ok && len(values) == 0 && !embedded {
+				return []string{"auth-user-pass: OpenVPN starts at boot on OpenWrt, pass a credentials file"}
+			}
+			return nil
+		},
+	},
+}
+
+// ClientTypes returns every known client type, sorted.
+func ClientTypes() []ClientType {
+	types := make([]ClientType, 0, len(clientTargets))
+	for t := range clientTargets {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// ParseClientType returns the ClientType named s.
+func ParseClientType(s string) (ClientType, error) {
+	t := ClientType(strings.ToLower(s))
+	if _, ok := clientTargets[t]; !ok {
+		names := []string{}
+		for _, t := range ClientTypes() {
+			names = append(names, string(t))
+		}
+		return "", fmt.Errorf("unknown client type %q, expected one of: %s", s, strings.Join(names, ", "))
+	}
+	return t, nil
+}
+
+// AdaptClientConfig adjusts a client configuration for the given client
+// type. It returns warnings about the directives that were removed and the
+// ones the client does not support.
+func AdaptClientConfig(config *generator.Config, t ClientType) ([]string, error) {
+	target, ok := clientTargets[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown client type %q", t)
+	}
+
+	warnings := []string{}
+
+	for _, name := range sortedKeys(target.drop) {
+		if !config.Has(name) {
+			continue
+		}
+		_ = config.Remove(name)
+		warnings = append(warnings, fmt.Sprintf("%s: removed, %s", name, target.drop[name]))
+	}
+
+	if target.adjust != nil {
+		warnings = append(warnings, target.adjust(config)...)
+	}
+
+	for _, name := range sortedKeys(target.unsupported) {
+		if config.Has(name) {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", name, target.unsupported[name]))
+		}
+	}
+
+	return warnings, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/audit"
+	"log"
+	"os"
+	"strings"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit [OPTIONS] FILE...",
+	Short: "Check configuration files for insecure settings",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   auditFn,
+}
+
+type auditReport struct {
+	File     string          `json:"file"`
+	Findings []audit.Finding `json:"findings"`
+}
+
+func auditFn(cmd *cobra.Command, args []string) {
+	format, _ := cmd.Flags().GetString("format")
+	failOn, _ := cmd.Flags().GetString("fail-on")
+	expiryWindow, _ := cmd.Flags().GetDuration("expiry-window")
+	minRSABits, _ := cmd.Flags().GetInt("min-rsa-bits")
+
+	threshold, err := audit.ParseSeverity(failOn)
+	if err != nil {
+		log.Fatal("invalid --fail-on value: ", err)
+	}
+
+	opts := &audit.Options{
+		ExpiryWindow: expiryWindow,
+		MinRSABits:   minRSABits,
+	}
+
+	reports := []auditReport{}
+	failed := false
+
+	for _, file := range args {
+		config, err := readConfigFile(file)
+		if err != nil {
+			log.Fatalf("failed to read %q: %v", file, err)
+		}
+
+		findings := audit.Check(config, opts)
+		if max, ok := audit.Max(findings); ok && max >= threshold {
+			failed = true
+		}
+
+		reports = append(reports, auditReport{File: file, Findings: findings})
+	}
+
+	switch format {
+	case "json":
+		writeJSON(reports)
+	case "text":
+		for _, report := range reports {
+			if len(report.Findings) == 0 {
+				fmt.Printf("%s: no issues found\n", report.File)
+				continue
+			}
+			for _, finding := range report.Findings {
+				fmt.Printf("%s: %-8s %-24s %s\n", report.File, strings.ToUpper(finding.Severity.String()), finding.ID, finding.Message)
+			}
+		}
+	default:
+		log.Fatalf("unknown output format %q", format)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	auditCmd.Flags().StringP("format", "f", "text", "Output format (text or json)")
+	auditCmd.Flags().String("fail-on", "high", "Exit with a non-zero status if any finding is at least this severe (info, low, medium, high or critical)")
+	auditCmd.Flags().Duration("expiry-window", audit.DefaultOptions.ExpiryWindow, "Report certificates that expire within this period")
+	auditCmd.Flags().Int("min-rsa-bits", audit.DefaultOptions.MinRSABits, "Minimum accepted RSA key size")
+}
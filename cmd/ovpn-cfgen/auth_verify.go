@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/htpasswd"
+	"github.com/xiam/openvpn-config-generator/lib/output"
+	"github.com/xiam/openvpn-config-generator/lib/totp"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+var authVerifyCmd = &cobra.Command{
+	Use:   "auth-verify --htpasswd FILE [CREDENTIALS_FILE]",
+	Short: "Check the username and password of a client, to be run by auth-user-pass-verify",
+	Long: `Check the username and password of a client against an htpasswd file with
+bcrypt or argon2id hashes, it exits with status 0 if they match.
+
+Users enrolled with the totp command must also send a one-time code, either
+as a static-challenge response or appended to the password. Every code is
+accepted once, the last one of every user is kept in the --totp-state file.
+
+OpenVPN passes the credentials in a file with the username and the password
+on separate lines (via-file), or in the username and password environment
+variables (via-env) when CREDENTIALS_FILE is missing.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  authVerifyFn,
+}
+
+func authVerifyFn(cmd *cobra.Command, args []string) {
+	file, _ := cmd.Flags().GetString("htpasswd")
+	if file == "" {
+		log.Fatal("missing required --htpasswd parameter")
+	}
+
+	username, password := os.Getenv("username"), os.Getenv("password")
+	if len(args) > 0 {
+		var err error
+		if username, password, err = readCredentials(args[0]); err != nil {
+			log.Fatal("failed to read credentials: ", err)
+		}
+	}
+
+	if username == "" {
+		log.Fatal("auth-verify: missing username")
+	}
+
+	requireTOTP, _ := cmd.Flags().GetBool("require-totp")
+	stateFile, _ := cmd.Flags().GetString("totp-state")
+
+	users, err := htpasswd.Load(file)
+	if err != nil {
+		log.Fatalf("failed to read %q: %v", file, err)
+	}
+
+	secret, code := users.TOTP(username), ""
+	if secret != "" || strings.HasPrefix(password, "SCRV1:") {
+		if password, code, err = totp.SplitResponse(password); err != nil {
+			log.Fatalf("auth-verify: %q: %v", username, err)
+		}
+	}
+
+	if err := users.Verify(username, password); err != nil {
+		log.Fatalf("auth-verify: %q: %v", username, err)
+	}
+
+	if secret == "" {
+		if requireTOTP {
+			log.Fatalf("auth-verify: %q: not enrolled in TOTP", username)
+		}
+	} else {
+		if stateFile == "" {
+			log.Fatalf("auth-verify: %q: missing --totp-state, needed to reject reused one-time codes", username)
+		}
+
+		counter, ok, err := totp.Match(secret, code, time.Now())
+		if err != nil {
+			log.Fatalf("auth-verify: %q: %v", username, err)
+		}
+		if !ok {
+			log.Fatalf("auth-verify: %q: wrong one-time code", username)
+		}
+
+		// OpenVPN runs auth-user-pass-verify scripts one at a time, the
+		// state can't change while it's read and written.
+		state, err := totp.LoadState(stateFile)
+		if err != nil {
+			log.Fatalf("failed to read %q: %v", stateFile, err)
+		}
+		if !state.Accept(username, counter) {
+			log.Fatalf("auth-verify: %q: one-time code was already used", username)
+		}
+		writeTOTPState(stateFile, state)
+	}
+
+	log.Printf("auth-verify: %q: authenticated", username)
+}
+
+// writeTOTPState replaces file with state. The file is renamed into place, so
+// its directory must be writable by the user OpenVPN runs as.
+func writeTOTPState(file string, state *totp.State) {
+	if err := output.Disk.WriteFile(file, state.Bytes(), output.PrivatePerm, true); err != nil {
+		log.Fatalf("failed to write %q: %v", file, err)
+	}
+}
+
+// readCredentials reads the username and password lines OpenVPN writes with
+// auth-user-pass-verify via-file.
+func readCredentials(file string) (string, string, error) {
+	fp, err := os.Open(file)
+	if err != nil {
+		return "", "", err
+	}
+	defer fp.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() && len(lines) < 2 {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+
+	for len(lines) < 2 {
+		lines = append(lines, "")
+	}
+
+	return lines[0], lines[1], nil
+}
+
+func init() {
+	authVerifyCmd.Flags().String("htpasswd", "", "File with the username:hash of every user")
+	authVerifyCmd.Flags().Bool("require-totp", false, "Reject users that are not enrolled in TOTP")
+	authVerifyCmd.Flags().String("totp-state", "", "File with the last one-time code accepted from every user, required by users enrolled in TOTP")
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"github.com/spf13/cobra"
-	ovpncfg "github.com/xiam/openvpn-config-generator"
 	"github.com/xiam/openvpn-config-generator/lib/certtool"
 	"log"
 	"path"
@@ -26,14 +25,18 @@ func buildCAFn(cmd *cobra.Command, args []string) {
 		log.Fatal("failed to build CA: ", err)
 	}
 
-	certFile := path.Join(workdir, fmt.Sprintf("%s.crt", basename))
-	if err := ovpncfg.WriteCert(caCert, certFile); err != nil {
-		log.Fatal("failed to write certificate: ", err)
-	}
+	out := newWriter(cmd)
 
+	// The key goes first, so that an existing key is never left next
+	// to a certificate it doesn't match.
 	keyFile := path.Join(workdir, fmt.Sprintf("%s.key", basename))
-	if err := ovpncfg.WriteKey(caKey, keyFile); err != nil {
-		log.Fatal("failed to write key: ", err)
+	if err := out.WriteKey(caKey, keyFile); err != nil {
+		log.Fatal("failed to write key (use --force to overwrite it): ", err)
+	}
+
+	certFile := path.Join(workdir, fmt.Sprintf("%s.crt", basename))
+	if err := out.WriteCert(caCert, certFile); err != nil {
+		log.Fatal("failed to write certificate: ", err)
 	}
 
 	log.Printf(`Your new CA certificate was successfully generated.`)
@@ -44,4 +47,5 @@ func buildCAFn(cmd *cobra.Command, args []string) {
 func init() {
 	buildCACmd.Flags().String("basename", "ca", "Base name of the CA files (e.g.: {$basename}.{crt,key}).")
 	buildCACmd.Flags().String("workdir", ".", "Work directory")
+	buildCACmd.Flags().Bool("force", false, "Overwrite existing private keys")
 }
@@ -4,7 +4,6 @@ import (
 	"crypto/x509"
 	"fmt"
 	"github.com/spf13/cobra"
-	ovpncfg "github.com/xiam/openvpn-config-generator"
 	"github.com/xiam/openvpn-config-generator/lib/certtool"
 	"log"
 	"path"
@@ -53,14 +52,16 @@ func buildKeyServerFn(cmd *cobra.Command, args []string) {
 
 	workdir, _ := cmd.Flags().GetString("workdir")
 
-	certFile := path.Join(workdir, fmt.Sprintf("%s.crt", basename))
-	if err := ovpncfg.WriteCert(serverCert, certFile); err != nil {
-		log.Fatal("failed to write certificate: ", err)
-	}
+	out := newWriter(cmd)
 
 	keyFile := path.Join(workdir, fmt.Sprintf("%s.key", basename))
-	if err := ovpncfg.WriteKey(serverKey, keyFile); err != nil {
-		log.Fatal("failed to write key: ", err)
+	if err := out.WriteKey(serverKey, keyFile); err != nil {
+		log.Fatal("failed to write key (use --force to overwrite it): ", err)
+	}
+
+	certFile := path.Join(workdir, fmt.Sprintf("%s.crt", basename))
+	if err := out.WriteCert(serverCert, certFile); err != nil {
+		log.Fatal("failed to write certificate: ", err)
 	}
 
 	log.Printf(`Your new server certificate was successfully generated.`)
@@ -73,4 +74,5 @@ func init() {
 	buildKeyServerCmd.Flags().String("workdir", ".", "Work directory")
 	buildKeyServerCmd.Flags().StringP("cert", "c", "ca.crt", "CA certificate path")
 	buildKeyServerCmd.Flags().StringP("key", "k", "ca.key", "CA private key path")
+	buildKeyServerCmd.Flags().Bool("force", false, "Overwrite existing private keys")
 }
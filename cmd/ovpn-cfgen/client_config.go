@@ -4,8 +4,10 @@ import (
 	"encoding/pem"
 	"github.com/spf13/cobra"
 	ovpncfg "github.com/xiam/openvpn-config-generator"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
 	"io/ioutil"
 	"log"
+	"strings"
 )
 
 var clientConfigCmd = &cobra.Command{
@@ -14,12 +16,13 @@ var clientConfigCmd = &cobra.Command{
 	Run:   clientConfigFn,
 }
 
-func clientConfigFn(cmd *cobra.Command, args []string) {
+// buildClientConfig creates an inline client configuration from the flags
+// added by clientConfigFlags.
+func buildClientConfig(cmd *cobra.Command) *generator.Config {
 	caCert, _ := cmd.Flags().GetString("ca")
 	cert, _ := cmd.Flags().GetString("cert")
 	key, _ := cmd.Flags().GetString("key")
 	tlsKey, _ := cmd.Flags().GetString("tls-crypt")
-	output, _ := cmd.Flags().GetString("output")
 
 	remote, _ := cmd.Flags().GetString("remote")
 	if remote == "" {
@@ -60,24 +63,127 @@ func clientConfigFn(cmd *cobra.Command, args []string) {
 
 	config.MustEmbed("ca", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCertBytes}))
 
-	config.MustEmbed("cert", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}))
-	config.MustEmbed("key", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: keyBytes}))
+	if usePKCS12, _ := cmd.Flags().GetBool("pkcs12"); usePKCS12 {
+		config.MustEmbed("pkcs12", wrapBase64(buildPKCS12(cmd, caCertBytes, certBytes, keyBytes)))
+	} else {
+		config.MustEmbed("cert", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}))
+		config.MustEmbed("key", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: keyBytes}))
+	}
 
 	config.MustEmbed("tls-crypt", tlsKeyBytes)
 
-	err = ovpncfg.WriteConfig(config, output)
+	authUserPass, _ := cmd.Flags().GetBool("auth-user-pass")
+	authUserPassFile, _ := cmd.Flags().GetString("auth-user-pass-file")
+	staticChallenge, _ := cmd.Flags().GetString("static-challenge")
+	if staticChallenge != "" {
+		if authUserPassFile != "" {
+			log.Fatal("--static-challenge asks for a code on every connection, it can't be used with --auth-user-pass-file")
+		}
+		authUserPass = true
+		if err := config.Set("static-challenge", staticChallenge, 1); err != nil {
+			log.Fatal("invalid --static-challenge: ", err)
+		}
+		// Renegotiations would ask for a code again.
+		config.MustSet("reneg-sec", 0)
+	}
+	if authUserPassFile != "" {
+		username, password, err := readCredentials(authUserPassFile)
+		if err != nil {
+			log.Fatal("failed to read --auth-user-pass-file: ", err)
+		}
+		if username == "" || password == "" {
+			log.Fatal("--auth-user-pass-file must hold the username and the password on separate lines")
+		}
+		config.MustEmbed("auth-user-pass", []byte(username+"\n"+password))
+	} else if authUserPass {
+		// The client asks for the credentials.
+		config.MustEnable("auth-user-pass")
+	}
+
+	clientType, _ := cmd.Flags().GetString("client-type")
+	adaptClientConfig(config, clientType)
+
+	return config
+}
+
+// adaptClientConfig adjusts config for the given client type and logs the
+// directives the client does not support.
+func adaptClientConfig(config *generator.Config, clientType string) {
+	t, err := ovpncfg.ParseClientType(clientType)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	warnings, err := ovpncfg.AdaptClientConfig(config, t)
 	if err != nil {
-		log.Fatal("could not write config file: ", err)
+		log.Fatal(err)
 	}
 
+	for _, warning := range warnings {
+		log.Printf("warning: %s: %s", t, warning)
+	}
+}
+
+func clientConfigFn(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+
+	config := buildClientConfig(cmd)
+
+	writeConfig(cmd, config, output, clientConfigSources(cmd))
+
 	log.Printf(`Your new client configuration file was written to: %q`, output)
+
+	// The QR code holds the inline profile, even with --split.
+	qrContent, _ := cmd.Flags().GetString("qr-url")
+	if qrContent == "" {
+		buf, err := config.Compile()
+		if err != nil {
+			log.Fatal("failed to compile config: ", err)
+		}
+		qrContent = string(buf)
+	}
+	writeQR(cmd, []byte(qrContent))
+}
+
+// clientConfigSources maps the blocks embedded by buildClientConfig to the
+// files they're read from.
+func clientConfigSources(cmd *cobra.Command) map[string]string {
+	sources := map[string]string{}
+	for _, directive := range []string{"ca", "cert", "key", "tls-crypt"} {
+		sources[directive], _ = cmd.Flags().GetString(directive)
+	}
+	if file, _ := cmd.Flags().GetString("auth-user-pass-file"); file != "" {
+		sources["auth-user-pass"] = file
+	}
+	return sources
+}
+
+func clientTypeNames() string {
+	names := []string{}
+	for _, t := range ovpncfg.ClientTypes() {
+		names = append(names, string(t))
+	}
+	return strings.Join(names, ", ")
+}
+
+func clientConfigFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("ca", "r", "ca.crt", "CA certificate")
+	cmd.Flags().StringP("cert", "c", "client.crt", "Certificate")
+	cmd.Flags().StringP("key", "k", "client.key", "Private key")
+	cmd.Flags().StringP("tls-crypt", "t", "key.tlsauth", "TLS Authentication key")
+	cmd.Flags().String("remote", "", "Address of the remote OpenVPN server")
+	cmd.Flags().String("client-type", string(ovpncfg.ClientGeneric), "Client application the profile is meant for: "+clientTypeNames())
+	cmd.Flags().Bool("pkcs12", false, "Embed a password protected PKCS#12 bundle instead of the certificate and key")
+	cmd.Flags().Bool("auth-user-pass", false, "Ask for a username and password when connecting, for servers with auth-user-pass-verify")
+	cmd.Flags().String("auth-user-pass-file", "", "Embed the username and password, on separate lines of this file, instead of asking for them")
+	cmd.Flags().String("static-challenge", "", "Also ask for a one-time code with this prompt (e.g.: \"Authenticator code\"), implies --auth-user-pass")
+	pkcs12Flags(cmd)
 }
 
 func init() {
-	clientConfigCmd.Flags().StringP("ca", "r", "ca.crt", "CA certificate")
-	clientConfigCmd.Flags().StringP("cert", "c", "client.crt", "Certificate")
-	clientConfigCmd.Flags().StringP("key", "k", "client.key", "Private key")
-	clientConfigCmd.Flags().StringP("tls-crypt", "t", "key.tlsauth", "TLS Authentication key")
-	clientConfigCmd.Flags().String("remote", "", "Address of the remote OpenVPN server")
+	clientConfigFlags(clientConfigCmd)
 	clientConfigCmd.Flags().StringP("output", "o", "client.ovpn", "Output file")
+	splitFlags(clientConfigCmd)
+	qrFlags(clientConfigCmd)
+	clientConfigCmd.Flags().String("qr-url", "", "Encode this URL (e.g.: a link created by share) instead of the profile in the QR code")
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/clientconnect"
+	"github.com/xiam/openvpn-config-generator/lib/firewall"
+	"github.com/xiam/openvpn-config-generator/lib/pki"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+var clientConnectCmd = &cobra.Command{
+	Use:   "client-connect --policy FILE [OPTIONS] CONFIG_FILE",
+	Short: "Write the configuration of a connecting client, to be run by client-connect",
+	Long: `Write the configuration of a connecting client to CONFIG_FILE, the file
+OpenVPN passes to client-connect scripts.
+
+The client, named by the common_name variable, gets the static addresses
+and pushed options of a policy, the same file the firewall command reads:
+
+  group admins alice bob
+  address alice 10.9.0.10 fd00:9::10
+  push @admins route 192.168.1.0 255.255.255.0
+  push * dhcp-option DNS 192.168.1.53
+
+Clients without an address statement get one from the pool. With --index,
+clients whose certificate is not valid in the index.txt file are rejected.`,
+	Args: cobra.ExactArgs(1),
+	Run:  clientConnectFn,
+}
+
+func clientConnectFn(cmd *cobra.Command, args []string) {
+	policyFile, _ := cmd.Flags().GetString("policy")
+	indexFile, _ := cmd.Flags().GetString("index")
+	netmask, _ := cmd.Flags().GetString("netmask")
+
+	if policyFile == "" {
+		log.Fatal("missing required --policy parameter")
+	}
+
+	env := clientconnect.EnvFromGetenv(os.Getenv)
+	if env.Netmask == "" {
+		env.Netmask = netmask
+	}
+
+	if indexFile != "" {
+		fp, err := os.Open(indexFile)
+		if err != nil {
+			log.Fatal("failed to open index: ", err)
+		}
+		entries, err := pki.ReadIndex(fp)
+		fp.Close()
+		if err != nil {
+			log.Fatalf("failed to parse %q: %v", indexFile, err)
+		}
+
+		entry := pki.Lookup(entries, env.CommonName)
+		if entry == nil {
+			log.Fatalf("client-connect: %q: not in %q", env.CommonName, indexFile)
+		}
+		if entry.Status != pki.StatusValid {
+			log.Fatalf("client-connect: %q: certificate is %s", env.CommonName, entry.Status)
+		}
+	}
+
+	fp, err := os.Open(policyFile)
+	if err != nil {
+		log.Fatal("failed to open policy: ", err)
+	}
+	policy, err := firewall.ParsePolicy(fp)
+	fp.Close()
+	if err != nil {
+		log.Fatalf("failed to parse %q: %v", policyFile, err)
+	}
+
+	config, err := clientconnect.Config(policy, env)
+	if err != nil {
+		log.Fatalf("client-connect: %q: %v", env.CommonName, err)
+	}
+
+	buf, err := config.Compile()
+	if err != nil {
+		log.Fatal("failed to compile config: ", err)
+	}
+
+	// OpenVPN creates the file and reads it once the script exits.
+	if err := ioutil.WriteFile(args[0], append(buf, '\n'), 0600); err != nil {
+		log.Fatalf("failed to write %q: %v", args[0], err)
+	}
+
+	log.Printf("client-connect: %q: wrote %d directives", env.CommonName, len(config.Names()))
+}
+
+func init() {
+	clientConnectCmd.Flags().String("policy", "", "File with the static addresses and pushed options of clients and groups")
+	clientConnectCmd.Flags().String("index", "", "Reject clients that are not valid in this OpenSSL/easy-rsa index.txt file")
+	clientConnectCmd.Flags().String("netmask", "", "Netmask of static IPv4 addresses when OpenVPN does not set ifconfig_netmask")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/expiry"
+	"github.com/xiam/openvpn-config-generator/lib/status"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+var clientsCmd = &cobra.Command{
+	Use:   "clients [OPTIONS]",
+	Short: "List connected clients along with their certificates",
+	Run:   clientsFn,
+}
+
+type clientEntry struct {
+	CommonName string `json:"common_name"`
+	Connected  bool   `json:"connected"`
+
+	*status.Client `json:"connection,omitempty"`
+
+	Certificate *expiry.Certificate `json:"certificate,omitempty"`
+}
+
+func clientsFn(cmd *cobra.Command, args []string) {
+	statusFile, _ := cmd.Flags().GetString("status")
+	workdir, _ := cmd.Flags().GetString("workdir")
+	format, _ := cmd.Flags().GetString("format")
+	all, _ := cmd.Flags().GetBool("all")
+
+	fp, err := os.Open(statusFile)
+	if err != nil {
+		log.Fatal("failed to open status file: ", err)
+	}
+	st, err := status.Parse(fp)
+	fp.Close()
+	if err != nil {
+		log.Fatal("failed to parse status file: ", err)
+	}
+
+	certs, err := expiry.ScanDir(workdir)
+	if err != nil {
+		log.Fatal("failed to scan work directory: ", err)
+	}
+
+	report := expiry.Check(certs, time.Now(), 30*24*time.Hour)
+
+	issued := map[string]*expiry.Certificate{}
+	for i, cert := range report.Certificates {
+		if cert.Role != "client" {
+			continue
+		}
+		if _, ok := issued[cert.CommonName]; !ok {
+			issued[cert.CommonName] = &report.Certificates[i]
+		}
+	}
+
+	entries := []clientEntry{}
+	for i := range st.Clients {
+		client := &st.Clients[i]
+		entries = append(entries, clientEntry{
+			CommonName:  client.CommonName,
+			Connected:   true,
+			Client:      client,
+			Certificate: issued[client.CommonName],
+		})
+		delete(issued, client.CommonName)
+	}
+
+	if all {
+		names := []string{}
+		for name := range issued {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			entries = append(entries, clientEntry{CommonName: name, Certificate: issued[name]})
+		}
+	}
+
+	switch format {
+	case "json":
+		writeJSON(entries)
+	case "text":
+		fmt.Printf("%-24s %-16s %-22s %12s %12s %-20s %s\n", "COMMON NAME", "VIRTUAL ADDRESS", "REAL ADDRESS", "RECEIVED", "SENT", "CONNECTED SINCE", "CERTIFICATE EXPIRES")
+		for _, entry := range entries {
+			var (
+				virtual, realAddress, since, expires = "-", "-", "-", "unknown"
+				received, sent                       = "-", "-"
+			)
+			if entry.Client != nil {
+				virtual, realAddress = entry.VirtualAddress, entry.RealAddress
+				received, sent = fmt.Sprint(entry.BytesReceived), fmt.Sprint(entry.BytesSent)
+				since = entry.ConnectedSince.Format("2006-01-02 15:04:05")
+			}
+			if entry.Certificate != nil {
+				expires = entry.Certificate.NotAfter.Format(time.RFC3339)
+			}
+			fmt.Printf("%-24s %-16s %-22s %12s %12s %-20s %s\n", entry.CommonName, virtual, realAddress, received, sent, since, expires)
+		}
+	default:
+		log.Fatalf("unknown output format %q", format)
+	}
+}
+
+func init() {
+	clientsCmd.Flags().String("status", "openvpn-status.log", "OpenVPN status file")
+	clientsCmd.Flags().String("workdir", ".", "Directory holding the issued certificates")
+	clientsCmd.Flags().StringP("format", "f", "text", "Output format (text or json)")
+	clientsCmd.Flags().Bool("all", false, "Also list clients that hold a certificate but are not connected")
+}
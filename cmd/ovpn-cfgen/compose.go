@@ -0,0 +1,110 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/archive"
+	"github.com/xiam/openvpn-config-generator/lib/compose"
+	"github.com/xiam/openvpn-config-generator/lib/container"
+	"github.com/xiam/openvpn-config-generator/lib/output"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+var composeCmd = &cobra.Command{
+	Use:   "compose [OPTIONS]",
+	Short: "Create a docker-compose bundle that runs the server in a container",
+	Long: `Create a docker-compose bundle that runs the server in a container.
+
+The server runs on a stock Alpine image, no Dockerfile is needed:
+
+  docker-compose.yml
+  .env             PORT, PROTO, NETWORK and NETWORK_MASK of the server
+  entrypoint.sh    installs OpenVPN, sets up NAT and starts the server
+  openvpn/         server configuration, mounted on /etc/openvpn
+  openvpn/ccd/     per-client configuration directory
+  pki/             certificates and keys, mounted read-only
+
+It takes the same options as server-config.`,
+	Run: composeFn,
+}
+
+func composeFn(cmd *cobra.Command, args []string) {
+	outputDir, _ := cmd.Flags().GetString("output-dir")
+	name, _ := cmd.Flags().GetString("name")
+	service, _ := cmd.Flags().GetString("service")
+	image, _ := cmd.Flags().GetString("image")
+
+	rejectScriptFlags(cmd)
+	config := buildServerConfig(cmd)
+
+	env, err := container.EnvFromConfig(config)
+	if err != nil {
+		log.Fatal("invalid server configuration: ", err)
+	}
+
+	configName := name + ".conf"
+
+	composeFile, err := compose.Compose(compose.Options{
+		Service: service,
+		Image:   image,
+		Config:  configName,
+	})
+	if err != nil {
+		log.Fatal("failed to create compose file: ", err)
+	}
+
+	// The configuration references the certificates and keys mounted on
+	// the pki directory.
+	config, files, err := config.Split(func(directive string) string {
+		return path.Join(compose.PKIDir, archive.FileName(directive, name))
+	})
+	if err != nil {
+		log.Fatal("could not split config file: ", err)
+	}
+
+	for _, dir := range []string{compose.PKIDir, filepath.Join(compose.ConfigDir, "ccd")} {
+		if err := os.MkdirAll(filepath.Join(outputDir, dir), 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	out := newWriter(cmd)
+
+	writeFiles(out, outputDir, files)
+
+	configFile := filepath.Join(outputDir, compose.ConfigDir, configName)
+	if err := out.WriteConfig(config, configFile); err != nil {
+		log.Fatal("could not write config file: ", err)
+	}
+	log.Printf("wrote %q", configFile)
+
+	for _, f := range []struct {
+		name string
+		data []byte
+		perm os.FileMode
+	}{
+		{compose.ComposeFile, composeFile, output.PublicPerm},
+		{compose.EnvFile, env.File(), output.PublicPerm},
+		{compose.EntrypointFile, compose.Entrypoint(), 0755},
+	} {
+		dest := filepath.Join(outputDir, f.name)
+		if err := out.WriteFile(dest, f.data, f.perm); err != nil {
+			log.Fatalf("could not write %s: %v", dest, err)
+		}
+		log.Printf("wrote %q", dest)
+	}
+
+	log.Printf(`Your compose bundle was written to: %q, start the server with:`, outputDir)
+	log.Printf(`  cd %s && docker compose up -d`, outputDir)
+}
+
+func init() {
+	serverConfigFlags(composeCmd)
+	composeCmd.Flags().StringP("output-dir", "o", "openvpn-compose", "Directory where the bundle is written to")
+	composeCmd.Flags().String("name", "server", "Name of the server configuration file")
+	composeCmd.Flags().String("service", "openvpn", "Name of the docker-compose service")
+	composeCmd.Flags().String("image", container.DefaultImage, "Alpine based image the server runs on")
+	composeCmd.Flags().Bool("force", false, "Overwrite existing private keys")
+}
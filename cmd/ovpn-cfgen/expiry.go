@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/expiry"
+	"log"
+	"os"
+	"time"
+)
+
+var expiryCmd = &cobra.Command{
+	Use:   "expiry [OPTIONS]",
+	Short: "Report certificates that are about to expire",
+	Long: `Report certificates that are about to expire.
+
+Exits with status 0 if no certificate expires within the window, 1 if some
+certificate is expiring and 2 if some certificate already expired. The
+prometheus output format always includes every certificate and is suitable
+for the node exporter's textfile collector.`,
+	Run: expiryFn,
+}
+
+func expiryFn(cmd *cobra.Command, args []string) {
+	workdir, _ := cmd.Flags().GetString("workdir")
+	indexFile, _ := cmd.Flags().GetString("index")
+	window, _ := cmd.Flags().GetDuration("window")
+	format, _ := cmd.Flags().GetString("format")
+	all, _ := cmd.Flags().GetBool("all")
+
+	var (
+		certs []expiry.Certificate
+		err   error
+	)
+
+	if indexFile != "" {
+		fp, err := os.Open(indexFile)
+		if err != nil {
+			log.Fatal("failed to open index: ", err)
+		}
+		certs, err = expiry.ParseIndex(fp)
+		fp.Close()
+		if err != nil {
+			log.Fatal("failed to parse index: ", err)
+		}
+	} else {
+		certs, err = expiry.ScanDir(workdir)
+		if err != nil {
+			log.Fatal("failed to scan work directory: ", err)
+		}
+	}
+
+	report := expiry.Check(certs, time.Now(), window)
+	if !all && format != "prometheus" {
+		report.Certificates = report.Filter()
+	}
+
+	switch format {
+	case "json":
+		writeJSON(report)
+	case "prometheus":
+		if err := expiry.WritePrometheus(os.Stdout, report); err != nil {
+			log.Fatal("failed to write metrics: ", err)
+		}
+	case "text":
+		for _, cert := range report.Certificates {
+			fmt.Printf("%-8s  %s  %4d days  %-24s  %s\n", cert.Status, cert.NotAfter.UTC().Format(time.RFC3339), cert.DaysLeft, cert.CommonName, cert.Path)
+		}
+	default:
+		log.Fatalf("unknown output format %q", format)
+	}
+
+	switch {
+	case report.Count(expiry.StatusExpired) > 0:
+		os.Exit(2)
+	case report.Count(expiry.StatusExpiring) > 0:
+		os.Exit(1)
+	}
+}
+
+func init() {
+	expiryCmd.Flags().String("workdir", ".", "Directory to scan for certificates and configuration files")
+	expiryCmd.Flags().String("index", "", "Read certificates from an OpenSSL/easy-rsa index.txt file instead of scanning the work directory")
+	expiryCmd.Flags().Duration("window", 30*24*time.Hour, "Report certificates that expire within this period")
+	expiryCmd.Flags().StringP("format", "f", "text", "Output format (text, json or prometheus)")
+	expiryCmd.Flags().Bool("all", false, "List every certificate, not only the ones expiring within the window")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/archive"
+	"github.com/xiam/openvpn-config-generator/lib/output"
+	"log"
+	"path"
+	"strings"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [OPTIONS]",
+	Short: "Create a zip or tar.gz bundle with a client profile",
+	Long: `Create a zip or tar.gz bundle with a client profile.
+
+The bundle holds the same profile client-config creates. With --split, the
+certificates and keys are written to separate files (ca.crt, {name}.crt,
+{name}.key and ta.key) that the profile references, for clients that don't
+support inline profiles.`,
+	Run: exportFn,
+}
+
+func exportFn(cmd *cobra.Command, args []string) {
+	outputFile, _ := cmd.Flags().GetString("output")
+	formatName, _ := cmd.Flags().GetString("format")
+	name, _ := cmd.Flags().GetString("name")
+	split, _ := cmd.Flags().GetBool("split")
+
+	var format archive.Format
+	var err error
+	if formatName != "" {
+		format, err = archive.ParseFormat(formatName)
+	} else {
+		format, err = archive.FormatFromName(outputFile)
+	}
+	if err != nil {
+		log.Fatal(err, ", use --format")
+	}
+
+	if name == "" {
+		cert, _ := cmd.Flags().GetString("cert")
+		name = strings.TrimSuffix(path.Base(cert), path.Ext(cert))
+	}
+
+	config := buildClientConfig(cmd)
+
+	files, err := archive.Files(config, name, split)
+	if err != nil {
+		log.Fatal("failed to build bundle: ", err)
+	}
+
+	var buf bytes.Buffer
+	if err := archive.Write(&buf, format, files); err != nil {
+		log.Fatal("failed to build bundle: ", err)
+	}
+
+	if err := output.Default.WriteFile(outputFile, buf.Bytes(), output.PrivatePerm); err != nil {
+		log.Fatal("could not write bundle: ", err)
+	}
+
+	log.Printf(`Your client bundle was written to: %q`, outputFile)
+}
+
+func init() {
+	clientConfigFlags(exportCmd)
+	exportCmd.Flags().StringP("output", "o", "client.zip", "Output file")
+	exportCmd.Flags().String("format", "", "Archive format: zip or tar.gz (default: guessed from --output)")
+	exportCmd.Flags().String("name", "", "Name of the profile in the bundle (default: name of the certificate file)")
+	exportCmd.Flags().Bool("split", false, "Write certificates and keys to separate files")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	ovpncfg "github.com/xiam/openvpn-config-generator"
+	"github.com/xiam/openvpn-config-generator/lib/exporter"
+	"github.com/xiam/openvpn-config-generator/lib/management"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var exporterCmd = &cobra.Command{
+	Use:   "exporter [OPTIONS]",
+	Short: "Serve Prometheus metrics about connected clients and certificates",
+	Run:   exporterFn,
+}
+
+func exporterFn(cmd *cobra.Command, args []string) {
+	listen, _ := cmd.Flags().GetString("listen")
+	metricsPath, _ := cmd.Flags().GetString("path")
+	statusFile, _ := cmd.Flags().GetString("status")
+	managementAddress, _ := cmd.Flags().GetString("management")
+	managementPasswordFile, _ := cmd.Flags().GetString("management-password-file")
+	workdir, _ := cmd.Flags().GetString("workdir")
+	window, _ := cmd.Flags().GetDuration("expiry-window")
+
+	e := &exporter.Exporter{
+		Workdir:      workdir,
+		ExpiryWindow: window,
+	}
+
+	switch {
+	case managementAddress != "":
+		opts := &management.Options{Timeout: 10 * time.Second}
+		if managementPasswordFile != "" {
+			buf, err := ioutil.ReadFile(managementPasswordFile)
+			if err != nil {
+				log.Fatal("failed to read management password: ", err)
+			}
+			opts.Password = strings.SplitN(string(buf), "\n", 2)[0]
+		}
+		network, address := ovpncfg.ManagementAddress(managementAddress)
+		e.Source = &exporter.Management{Network: network, Address: address, Options: opts}
+	case statusFile != "":
+		e.Source = exporter.StatusFile(statusFile)
+	default:
+		log.Fatal("either --status or --management is required")
+	}
+
+	http.Handle(metricsPath, e)
+
+	log.Printf("Serving metrics on %s%s", listen, metricsPath)
+	log.Fatal(http.ListenAndServe(listen, nil))
+}
+
+func init() {
+	exporterCmd.Flags().String("listen", ":9176", "Address to listen on")
+	exporterCmd.Flags().String("path", "/metrics", "Path under which metrics are served")
+	exporterCmd.Flags().String("status", "", "OpenVPN status file")
+	exporterCmd.Flags().String("management", "", "Management interface address, either a unix socket path or host:port")
+	exporterCmd.Flags().String("management-password-file", "", "File holding the management interface password")
+	exporterCmd.Flags().String("workdir", "", "Directory to scan for certificates to report their expiration, disabled if empty")
+	exporterCmd.Flags().Duration("expiry-window", 30*24*time.Hour, "Count certificates that expire within this period as expiring")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/deploy"
+	"github.com/xiam/openvpn-config-generator/lib/firewall"
+	"github.com/xiam/openvpn-config-generator/lib/output"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var firewallCmd = &cobra.Command{
+	Use:   "firewall [OPTIONS] FILE",
+	Short: "Create firewall rules for a server configuration file",
+	Long: `Create firewall rules for a server configuration file.
+
+The rules accept connections on the port the server listens on, forward the
+traffic of clients and masquerade it. With --policy, clients can only reach
+the destinations the policy allows:
+
+  group admins alice bob
+  allow @admins 192.168.1.0/24 tcp/22
+  allow alice any
+  allow * 192.168.1.53 udp/53
+
+Rules match clients by the static addresses of the address statements of
+the policy (see client-connect) or else by the ones assigned by
+ifconfig-push and ifconfig-ipv6-push in the client-config-dir of the server.
+Traffic between clients only goes through the firewall without
+client-to-client.
+
+nftables rules live in a table of their own: another table whose chains
+drop traffic by default, like the filter table of many distributions, has to
+accept the port of the server and the traffic of clients too.`,
+	Args: cobra.ExactArgs(1),
+	Run:  firewallFn,
+}
+
+type rulesFile struct {
+	name string
+	data []byte
+}
+
+// firewallFiles returns the files holding rules in format, named after the
+// layout of package deploy.
+func firewallFiles(rules *firewall.Ruleset, format string) []rulesFile {
+	files := []rulesFile{}
+
+	add := func(name string, data []byte, err error) {
+		if err != nil {
+			log.Fatal("failed to create firewall rules: ", err)
+		}
+		files = append(files, rulesFile{name: name, data: data})
+	}
+
+	switch format {
+	case "nftables":
+		data, err := rules.NFTables()
+		add(deploy.NFTablesFile(rules.Name), data, err)
+	case "iptables":
+		data, err := rules.IPTables()
+		add(deploy.IPTablesFile(rules.Name), data, err)
+		if rules.HasIPv6() {
+			data, err := rules.IP6Tables()
+			add(deploy.IP6TablesFile(rules.Name), data, err)
+		}
+	case "none":
+	default:
+		log.Fatalf("unknown firewall %q, use nftables, iptables or none", format)
+	}
+
+	return files
+}
+
+func firewallFn(cmd *cobra.Command, args []string) {
+	name, _ := cmd.Flags().GetString("name")
+	format, _ := cmd.Flags().GetString("format")
+	wanInterface, _ := cmd.Flags().GetString("wan-interface")
+	ccd, _ := cmd.Flags().GetString("ccd")
+	policyFile, _ := cmd.Flags().GetString("policy")
+	outputDir, _ := cmd.Flags().GetString("output-dir")
+
+	config, err := readConfigFile(args[0])
+	if err != nil {
+		log.Fatalf("failed to read %q: %v", args[0], err)
+	}
+
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+	}
+
+	rules, err := firewall.FromConfig(name, config)
+	if err != nil {
+		log.Fatal("failed to create firewall rules: ", err)
+	}
+	rules.Interface = wanInterface
+
+	if policyFile != "" {
+		fp, err := os.Open(policyFile)
+		if err != nil {
+			log.Fatal("failed to open policy: ", err)
+		}
+		rules.Policy, err = firewall.ParsePolicy(fp)
+		fp.Close()
+		if err != nil {
+			log.Fatalf("failed to parse %q: %v", policyFile, err)
+		}
+
+		// OpenVPN routes traffic between clients internally, it never
+		// reaches the forward chain.
+		if config.Has("client-to-client") {
+			log.Printf("warning: %q enables client-to-client, the policy can't restrict traffic between clients", args[0])
+		}
+
+		// client-config-dir is relative to the working directory of
+		// OpenVPN, assume it's the one of FILE.
+		if ccd == "" {
+			if values, ok := config.Get("client-config-dir"); ok && len(values) > 0 {
+				ccd = values[0]
+				if !filepath.IsAbs(ccd) {
+					ccd = filepath.Join(filepath.Dir(args[0]), ccd)
+				}
+			}
+		}
+		if ccd != "" {
+			if rules.Clients, err = firewall.ReadClients(ccd); err != nil {
+				log.Fatalf("failed to read %q: %v", ccd, err)
+			}
+		}
+	}
+
+	files := firewallFiles(rules, format)
+
+	if outputDir == "" {
+		for _, f := range files {
+			if len(files) > 1 {
+				os.Stdout.WriteString("# " + f.name + "\n")
+			}
+			os.Stdout.Write(f.data)
+		}
+		return
+	}
+
+	for _, f := range files {
+		dest := filepath.Join(outputDir, filepath.Base(f.name))
+		if err := output.Default.WriteFile(dest, f.data, output.PublicPerm); err != nil {
+			log.Fatalf("could not write %s: %v", dest, err)
+		}
+		log.Printf("wrote %q", dest)
+	}
+}
+
+func init() {
+	firewallCmd.Flags().String("name", "", "Name of the rules (default: name of FILE)")
+	firewallCmd.Flags().String("format", "nftables", "Format of the rules: nftables or iptables")
+	firewallCmd.Flags().String("wan-interface", "", "Interface client traffic leaves through (default: any)")
+	firewallCmd.Flags().String("policy", "", "File with the access rules of clients and groups")
+	firewallCmd.Flags().String("ccd", "", "Directory with the static addresses of clients (default: client-config-dir of FILE)")
+	firewallCmd.Flags().StringP("output-dir", "o", "", "Directory where the rules are written to (default: standard output)")
+}
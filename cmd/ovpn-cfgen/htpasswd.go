@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/htpasswd"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+var htpasswdCmd = &cobra.Command{
+	Use:   "htpasswd [OPTIONS] FILE USERNAME",
+	Short: "Add a user to, or remove it from, the htpasswd file read by auth-verify",
+	Args:  cobra.ExactArgs(2),
+	Run:   htpasswdFn,
+}
+
+func htpasswdFn(cmd *cobra.Command, args []string) {
+	file, username := args[0], args[1]
+
+	remove, _ := cmd.Flags().GetBool("delete")
+	useBcrypt, _ := cmd.Flags().GetBool("bcrypt")
+	cost, _ := cmd.Flags().GetInt("cost")
+
+	users, err := htpasswd.Load(file)
+	if err != nil {
+		log.Fatalf("failed to read %q: %v", file, err)
+	}
+
+	if remove {
+		if !users.Remove(username) {
+			log.Fatalf("no user %q in %q", username, file)
+		}
+	} else {
+		password := readSecret(cmd, "password-file", "OPENVPN_PASSWORD")
+		if password == "" {
+			if password, err = readPasswordLine(); err != nil {
+				log.Fatal("failed to read password: ", err)
+			}
+		}
+		if password == "" {
+			log.Fatal("missing password, pass --password-file or write it to stdin")
+		}
+
+		var hash string
+		if useBcrypt {
+			hash, err = htpasswd.HashBcrypt(password, cost)
+		} else {
+			hash, err = htpasswd.HashArgon2(password)
+		}
+		if err != nil {
+			log.Fatal("failed to hash password: ", err)
+		}
+
+		if err := users.Set(username, hash); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	writeHtpasswd(file, users)
+
+	if remove {
+		log.Printf("User %q was removed from %q", username, file)
+		return
+	}
+	log.Printf("The password of %q was written to %q", username, file)
+}
+
+// writeHtpasswd writes users to file.
+func writeHtpasswd(file string, users *htpasswd.File) {
+	// OpenVPN runs auth-verify after dropping privileges, so the group
+	// of the file must be able to read it.
+	if err := ioutil.WriteFile(file, users.Bytes(), 0640); err != nil {
+		log.Fatalf("failed to write %q: %v", file, err)
+	}
+}
+
+// readPasswordLine reads the first line of stdin.
+func readPasswordLine() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func init() {
+	htpasswdCmd.Flags().String("password-file", "", "File holding the password, read from stdin if not given (env: OPENVPN_PASSWORD)")
+	htpasswdCmd.Flags().Bool("bcrypt", false, "Hash the password with bcrypt instead of argon2id")
+	htpasswdCmd.Flags().Int("cost", htpasswd.DefaultCost, "Cost of bcrypt hashes")
+	htpasswdCmd.Flags().BoolP("delete", "D", false, "Remove the user")
+}
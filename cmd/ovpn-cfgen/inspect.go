@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/inspect"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [OPTIONS] FILE...",
+	Short: "Print the details of certificates, keys and parameters",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   inspectFn,
+}
+
+type inspectReport struct {
+	File    string            `json:"file"`
+	Objects []*inspect.Object `json:"objects"`
+}
+
+func inspectFn(cmd *cobra.Command, args []string) {
+	format, _ := cmd.Flags().GetString("format")
+
+	reports := []inspectReport{}
+	for _, file := range args {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Fatalf("failed to read %q: %v", file, err)
+		}
+
+		objects, err := inspect.File(buf)
+		if err != nil {
+			log.Fatalf("failed to inspect %q: %v", file, err)
+		}
+
+		reports = append(reports, inspectReport{File: file, Objects: objects})
+	}
+
+	switch format {
+	case "json":
+		writeJSON(reports)
+	case "text":
+		for _, report := range reports {
+			for _, obj := range report.Objects {
+				printObject(report.File, obj)
+			}
+		}
+	default:
+		log.Fatalf("unknown output format %q", format)
+	}
+}
+
+func printObject(file string, obj *inspect.Object) {
+	title := file
+	if obj.Source != "" {
+		title = fmt.Sprintf("%s <%s>", file, obj.Source)
+	}
+	fmt.Printf("%s: %s\n", title, obj.Kind)
+
+	field := func(name string, value string) {
+		if value != "" {
+			fmt.Printf("    %-20s %s\n", name+":", value)
+		}
+	}
+	timeField := func(name string, value *time.Time) {
+		if value != nil {
+			field(name, value.UTC().Format(time.RFC3339))
+		}
+	}
+
+	field("Subject", obj.Subject)
+	field("Issuer", obj.Issuer)
+	field("Serial", obj.Serial)
+	timeField("Not Before", obj.NotBefore)
+	timeField("Not After", obj.NotAfter)
+	timeField("This Update", obj.ThisUpdate)
+	timeField("Next Update", obj.NextUpdate)
+	if obj.IsCA {
+		field("CA", "yes")
+	}
+	field("Key Usage", strings.Join(obj.KeyUsage, ", "))
+	field("Extended Key Usage", strings.Join(obj.ExtKeyUsage, ", "))
+	field("DNS Names", strings.Join(obj.DNSNames, ", "))
+	if obj.KeyBits > 0 {
+		field("Key", fmt.Sprintf("%s %d bit", obj.KeyType, obj.KeyBits))
+	}
+	field("Signature", obj.Signature)
+	field("SHA-256 Fingerprint", obj.Fingerprint)
+	if obj.Kind == inspect.KindCRL {
+		field("Revoked", fmt.Sprintf("%d certificate(s)", len(obj.Revoked)))
+		for _, revoked := range obj.Revoked {
+			fmt.Printf("        %s revoked at %s\n", revoked.Serial, revoked.RevokedAt.UTC().Format(time.RFC3339))
+		}
+	}
+	fmt.Println("")
+}
+
+func init() {
+	inspectCmd.Flags().StringP("format", "f", "text", "Output format (text or json)")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/container"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+	"github.com/xiam/openvpn-config-generator/lib/k8s"
+	"github.com/xiam/openvpn-config-generator/lib/output"
+	"log"
+	"os"
+)
+
+var k8sCmd = &cobra.Command{
+	Use:   "k8s [OPTIONS] [FILE]",
+	Short: "Render a server configuration as Kubernetes manifests",
+	Long: `Render a server configuration as Kubernetes manifests.
+
+The configuration goes into a ConfigMap and its certificates and keys into a
+Secret. With --deployment, a Deployment and a Service that run the server
+are added. The configuration is read from FILE, a file created by
+server-config, or built from the same options as server-config:
+
+  ovpn-cfgen k8s server.conf --deployment | kubectl apply -f -
+
+The Deployment sets the net.ipv4.ip_forward sysctl to route the traffic of
+clients, the kubelet of its node must allow it with
+--allowed-unsafe-sysctls=net.ipv4.ip_forward.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  k8sFn,
+}
+
+func k8sFn(cmd *cobra.Command, args []string) {
+	name, _ := cmd.Flags().GetString("name")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	deployment, _ := cmd.Flags().GetBool("deployment")
+	image, _ := cmd.Flags().GetString("image")
+	serviceType, _ := cmd.Flags().GetString("service-type")
+	outputFile, _ := cmd.Flags().GetString("output")
+
+	var config *generator.Config
+	if len(args) > 0 {
+		var err error
+		if config, err = readConfigFile(args[0]); err != nil {
+			log.Fatalf("failed to read %q: %v", args[0], err)
+		}
+	} else {
+		rejectScriptFlags(cmd)
+		config = buildServerConfig(cmd)
+	}
+
+	buf, err := k8s.Manifests(config, k8s.Options{
+		Name:        name,
+		Namespace:   namespace,
+		Deployment:  deployment,
+		Image:       image,
+		ServiceType: serviceType,
+	})
+	if err != nil {
+		log.Fatal("failed to create manifests: ", err)
+	}
+
+	if outputFile == "-" {
+		os.Stdout.Write(buf)
+		return
+	}
+
+	// The manifests hold the private keys.
+	if err := output.Default.WriteFile(outputFile, buf, output.PrivatePerm); err != nil {
+		log.Fatal("could not write manifests: ", err)
+	}
+
+	log.Printf(`Your Kubernetes manifests were written to: %q`, outputFile)
+}
+
+func init() {
+	serverConfigFlags(k8sCmd)
+	k8sCmd.Flags().String("name", "openvpn", "Name of the resources")
+	k8sCmd.Flags().String("namespace", "", "Namespace of the resources (default: the one of kubectl)")
+	k8sCmd.Flags().Bool("deployment", false, "Add a Deployment and a Service that run the server")
+	k8sCmd.Flags().String("image", container.DefaultImage, "Alpine based image the server runs on")
+	k8sCmd.Flags().String("service-type", "LoadBalancer", "Type of the Service")
+	k8sCmd.Flags().StringP("output", "o", "-", "Output file, - for standard output")
+}
@@ -5,7 +5,27 @@ func main() {
 	rootCmd.AddCommand(buildKeyServerCmd)
 	rootCmd.AddCommand(buildKeyCmd)
 	rootCmd.AddCommand(serverConfigCmd)
+	rootCmd.AddCommand(serverBundleCmd)
+	rootCmd.AddCommand(firewallCmd)
+	rootCmd.AddCommand(composeCmd)
+	rootCmd.AddCommand(k8sCmd)
+	rootCmd.AddCommand(authVerifyCmd)
+	rootCmd.AddCommand(htpasswdCmd)
+	rootCmd.AddCommand(totpCmd)
+	rootCmd.AddCommand(clientConnectCmd)
 	rootCmd.AddCommand(clientConfigCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(inspectCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(expiryCmd)
+	rootCmd.AddCommand(clientsCmd)
+	rootCmd.AddCommand(exporterCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(shareCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(pkcs12Cmd)
+	rootCmd.AddCommand(nmconnectionCmd)
+	rootCmd.AddCommand(uciCmd)
 
 	rootCmd.Execute()
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	ovpncfg "github.com/xiam/openvpn-config-generator"
+	"github.com/xiam/openvpn-config-generator/lib/networkmanager"
+	"github.com/xiam/openvpn-config-generator/lib/output"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var nmconnectionCmd = &cobra.Command{
+	Use:   "nmconnection [OPTIONS]",
+	Short: "Create a NetworkManager keyfile with a client profile",
+	Long: `Create a NetworkManager keyfile with a client profile.
+
+The keyfile ({name}.nmconnection) is written to --output-dir along with the
+certificates and keys it references. The keyfile points to them by absolute
+path, under --cert-dir, so copy them there before loading the connection:
+
+  sudo cp laptop.nmconnection /etc/NetworkManager/system-connections/
+  sudo nmcli connection reload`,
+	Run: nmconnectionFn,
+}
+
+func nmconnectionFn(cmd *cobra.Command, args []string) {
+	name, _ := cmd.Flags().GetString("name")
+	outputDir, _ := cmd.Flags().GetString("output-dir")
+	certDir, _ := cmd.Flags().GetString("cert-dir")
+	username, _ := cmd.Flags().GetString("username")
+
+	if name == "" {
+		cert, _ := cmd.Flags().GetString("cert")
+		name = strings.TrimSuffix(path.Base(cert), path.Ext(cert))
+	}
+
+	if certDir == "" {
+		var err error
+		if certDir, err = filepath.Abs(outputDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if !cmd.Flags().Changed("client-type") {
+		cmd.Flags().Set("client-type", string(ovpncfg.ClientNetworkManager))
+	}
+
+	config := buildClientConfig(cmd)
+
+	opts := networkmanager.Options{
+		ID:       name,
+		Dir:      filepath.ToSlash(certDir),
+		Username: username,
+		Password: readSecret(cmd, "password-file", "OPENVPN_PASSWORD"),
+	}
+	if usePKCS12, _ := cmd.Flags().GetBool("pkcs12"); usePKCS12 {
+		opts.CertPass = readSecret(cmd, "pkcs12-password-file", "PKCS12_PASSWORD")
+	}
+
+	conn, err := networkmanager.Convert(config, opts)
+	if err != nil {
+		log.Fatal("failed to create keyfile: ", err)
+	}
+
+	for _, warning := range conn.Warnings {
+		log.Printf("warning: %s", warning)
+	}
+
+	out := newWriter(cmd)
+
+	writeFiles(out, outputDir, conn.Files)
+
+	// NetworkManager ignores keyfiles readable by other users.
+	keyfile := filepath.Join(outputDir, networkmanager.FileName(name))
+	if err := out.WriteFile(keyfile, conn.Keyfile, output.PrivatePerm); err != nil {
+		log.Fatal("could not write keyfile: ", err)
+	}
+
+	log.Printf(`Your NetworkManager keyfile was written to: %q, it expects the files above in %q`, keyfile, certDir)
+}
+
+func init() {
+	clientConfigFlags(nmconnectionCmd)
+	nmconnectionCmd.Flags().String("name", "", "Name of the connection (default: name of the certificate file)")
+	nmconnectionCmd.Flags().String("output-dir", ".", "Directory where the keyfile, certificates and keys are written to")
+	nmconnectionCmd.Flags().String("cert-dir", "", "Absolute path the certificates and keys are installed to (default: --output-dir)")
+	nmconnectionCmd.Flags().String("username", "", "Username, for servers that require auth-user-pass")
+	nmconnectionCmd.Flags().String("password-file", "", "File holding the auth-user-pass password, NetworkManager asks for it if not given (env: OPENVPN_PASSWORD)")
+	nmconnectionCmd.Flags().Bool("force", false, "Overwrite existing private keys")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/certtool"
+	"log"
+)
+
+var pkcs12Cmd = &cobra.Command{
+	Use:   "pkcs12 [OPTIONS]",
+	Short: "Bundle a client certificate, key and CA into a PKCS#12 file",
+	Long: `Bundle a client certificate, key and CA into a PKCS#12 file.
+
+The file is protected with the password read from --password-file or from
+the PKCS12_PASSWORD environment variable.`,
+	Run: pkcs12Fn,
+}
+
+// buildPKCS12 reads the password and bundles the given DER encoded
+// credentials.
+func buildPKCS12(cmd *cobra.Command, caCert []byte, cert []byte, key []byte) []byte {
+	modern, _ := cmd.Flags().GetBool("pkcs12-modern")
+
+	password := readSecret(cmd, "pkcs12-password-file", "PKCS12_PASSWORD")
+	if password == "" {
+		log.Fatal("missing PKCS#12 password, use --pkcs12-password-file or set PKCS12_PASSWORD")
+	}
+
+	buf, err := certtool.BuildPKCS12(cert, key, [][]byte{caCert}, password, modern)
+	if err != nil {
+		log.Fatal("failed to build PKCS#12 file: ", err)
+	}
+
+	return buf
+}
+
+func pkcs12Fn(cmd *cobra.Command, args []string) {
+	caCert, _ := cmd.Flags().GetString("ca")
+	cert, _ := cmd.Flags().GetString("cert")
+	key, _ := cmd.Flags().GetString("key")
+	output, _ := cmd.Flags().GetString("output")
+
+	checkFile(cmd, caCert, "missing CA certificate")
+	checkFile(cmd, cert, "missing certificate")
+	checkFile(cmd, key, "missing private key")
+
+	caCertBytes, err := readPemFile(caCert)
+	if err != nil {
+		log.Fatal("failed to parse CA certificate: ", err)
+	}
+
+	certBytes, err := readPemFile(cert)
+	if err != nil {
+		log.Fatal("failed to parse certificate: ", err)
+	}
+
+	keyBytes, err := readPemFile(key)
+	if err != nil {
+		log.Fatal("failed to parse private key: ", err)
+	}
+
+	buf := buildPKCS12(cmd, caCertBytes, certBytes, keyBytes)
+
+	if err := newWriter(cmd).WriteSecret(output, buf); err != nil {
+		log.Fatal("could not write PKCS#12 file (use --force to overwrite it): ", err)
+	}
+
+	log.Printf(`Your new PKCS#12 file was written to: %q`, output)
+}
+
+// pkcs12Flags adds the flags used by buildPKCS12.
+func pkcs12Flags(cmd *cobra.Command) {
+	cmd.Flags().String("pkcs12-password-file", "", "File holding the PKCS#12 password")
+	cmd.Flags().Bool("pkcs12-modern", false, "Encrypt the PKCS#12 file with AES-256 instead of 3DES, not supported by older clients")
+}
+
+func init() {
+	pkcs12Cmd.Flags().StringP("ca", "r", "ca.crt", "CA certificate")
+	pkcs12Cmd.Flags().StringP("cert", "c", "client.crt", "Certificate")
+	pkcs12Cmd.Flags().StringP("key", "k", "client.key", "Private key")
+	pkcs12Cmd.Flags().StringP("output", "o", "client.p12", "Output file")
+	pkcs12Cmd.Flags().Bool("force", false, "Overwrite an existing PKCS#12 file")
+	pkcs12Flags(pkcs12Cmd)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/output"
+	"github.com/xiam/openvpn-config-generator/lib/qr"
+	"log"
+	"os"
+)
+
+// qrFlags adds the flags used by writeQR.
+func qrFlags(cmd *cobra.Command) {
+	cmd.Flags().String("qr", "", "Also render a QR code: ansi (printed to the terminal) or png")
+	cmd.Flags().String("qr-output", "qr.png", "Output file of --qr png")
+	cmd.Flags().Int("qr-size", 512, "Width and height of --qr png, in pixels")
+}
+
+// writeQR renders content as a QR code as requested by the --qr flag, it
+// does nothing if the flag is not set.
+func writeQR(cmd *cobra.Command, content []byte) {
+	format, _ := cmd.Flags().GetString("qr")
+	if format == "" {
+		return
+	}
+
+	code, err := qr.New(content)
+	if err == qr.ErrTooLarge {
+		log.Fatalf("%v (%d bytes), share a link to it instead", err, len(content))
+	}
+	if err != nil {
+		log.Fatal("failed to create QR code: ", err)
+	}
+
+	switch format {
+	case "ansi":
+		if err := code.WriteANSI(os.Stdout); err != nil {
+			log.Fatal("failed to print QR code: ", err)
+		}
+	case "png":
+		file, _ := cmd.Flags().GetString("qr-output")
+		size, _ := cmd.Flags().GetInt("qr-size")
+
+		buf, err := code.PNG(size)
+		if err != nil {
+			log.Fatal("failed to create QR code: ", err)
+		}
+
+		// Both profiles and one-time links are secrets.
+		if err := output.Default.WriteFile(file, buf, output.PrivatePerm); err != nil {
+			log.Fatal("could not write QR code: ", err)
+		}
+		log.Printf(`The QR code was written to: %q`, file)
+	default:
+		log.Fatalf("unknown QR code format %q, expected ansi or png", format)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/api"
+	"github.com/xiam/openvpn-config-generator/lib/links"
+	"github.com/xiam/openvpn-config-generator/lib/pki"
+	"github.com/xiam/openvpn-config-generator/lib/webui"
+	"log"
+	"net/http"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [OPTIONS]",
+	Short: "Serve an HTTP API to create, download and revoke client profiles",
+	Long: `Serve an HTTP API and a web UI to create, download and revoke client profiles.
+
+The work directory must contain the CA certificate and key (ca.crt and
+ca.key) and the tls-crypt key (key.tlsauth). The API token is read from
+--token-file or from the API_TOKEN environment variable, the same token is
+used to sign in to the web UI.`,
+	Run: serveFn,
+}
+
+func serveFn(cmd *cobra.Command, args []string) {
+	listen, _ := cmd.Flags().GetString("listen")
+	workdir, _ := cmd.Flags().GetString("workdir")
+	remote, _ := cmd.Flags().GetString("remote")
+	tlsCert, _ := cmd.Flags().GetString("tls-cert")
+	tlsKey, _ := cmd.Flags().GetString("tls-key")
+	ui, _ := cmd.Flags().GetBool("ui")
+	linkTTL, _ := cmd.Flags().GetDuration("link-ttl")
+
+	if remote == "" {
+		log.Fatal("missing required --remote parameter")
+	}
+
+	token := readSecret(cmd, "token-file", "API_TOKEN")
+	if token == "" {
+		log.Fatal("missing API token, use --token-file or set API_TOKEN")
+	}
+
+	store, err := pki.Open(workdir)
+	if err != nil {
+		log.Fatal("failed to open work directory: ", err)
+	}
+
+	server, err := api.New(store, api.Options{
+		Token:   token,
+		Remote:  remote,
+		LinkTTL: linkTTL,
+	})
+	if err != nil {
+		log.Fatal("failed to start API: ", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(api.Prefix, server)
+	mux.Handle(api.DownloadPrefix, server.Downloads())
+	if ui {
+		mux.Handle("/", webui.Handler())
+	}
+
+	log.Printf("Serving API on %s", listen)
+	if tlsCert != "" {
+		log.Fatal(http.ListenAndServeTLS(listen, tlsCert, tlsKey, mux))
+	}
+	log.Fatal(http.ListenAndServe(listen, mux))
+}
+
+func init() {
+	serveCmd.Flags().String("listen", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().String("workdir", ".", "Work directory")
+	serveCmd.Flags().String("remote", "", "Address of the remote OpenVPN server")
+	serveCmd.Flags().String("token-file", "", "File holding the API token")
+	serveCmd.Flags().String("tls-cert", "", "Certificate to serve HTTPS with")
+	serveCmd.Flags().String("tls-key", "", "Private key to serve HTTPS with")
+	serveCmd.Flags().Bool("ui", true, "Serve the web UI")
+	serveCmd.Flags().Duration("link-ttl", links.DefaultTTL, "How long one-time download links stay valid")
+}
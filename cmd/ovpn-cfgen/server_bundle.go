@@ -0,0 +1,124 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/deploy"
+	"github.com/xiam/openvpn-config-generator/lib/firewall"
+	"github.com/xiam/openvpn-config-generator/lib/output"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+var serverBundleCmd = &cobra.Command{
+	Use:   "server-bundle [OPTIONS]",
+	Short: "Create a server configuration along with the files needed to run it with systemd",
+	Long: `Create a server configuration along with the files needed to run it with systemd.
+
+The files are laid out under --output-dir as they're installed:
+
+  etc/openvpn/server/{name}.conf          read by openvpn-server@{name}.service
+  etc/openvpn/server/ccd/                 per-client configuration directory
+  etc/sysctl.d/30-openvpn-forward.conf    enables IP forwarding
+  etc/nftables.d/openvpn-{name}.nft       firewall rules (--firewall nftables)
+  etc/iptables/openvpn-{name}.rules.v4    firewall rules (--firewall iptables)
+  etc/tmpfiles.d/openvpn-{name}.conf      creates the directory of --totp-state (--htpasswd)
+  etc/systemd/system/openvpn-server@.service (--unit)
+
+It takes the same options as server-config.`,
+	Run: serverBundleFn,
+}
+
+func serverBundleFn(cmd *cobra.Command, args []string) {
+	outputDir, _ := cmd.Flags().GetString("output-dir")
+	name, _ := cmd.Flags().GetString("name")
+	wanInterface, _ := cmd.Flags().GetString("wan-interface")
+	firewallType, _ := cmd.Flags().GetString("firewall")
+	withUnit, _ := cmd.Flags().GetBool("unit")
+
+	config := buildServerConfig(cmd)
+
+	rules, err := firewall.FromConfig(name, config)
+	if err != nil {
+		log.Fatal("failed to create firewall rules: ", err)
+	}
+	rules.Interface = wanInterface
+
+	firewallRules := firewallFiles(rules, firewallType)
+
+	dest := func(file string) string {
+		return filepath.Join(outputDir, filepath.FromSlash(file))
+	}
+
+	for _, dir := range []string{deploy.ConfigDir, filepath.Join(deploy.ConfigDir, "ccd")} {
+		if err := os.MkdirAll(dest(dir), 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	configFile := dest(deploy.ConfigFile(name))
+	writeConfig(cmd, config, configFile, serverConfigSources(cmd))
+	log.Printf("wrote %q", configFile)
+
+	write := func(file string, data []byte) {
+		if err := os.MkdirAll(filepath.Dir(dest(file)), 0755); err != nil {
+			log.Fatal(err)
+		}
+		if err := output.Default.WriteFile(dest(file), data, output.PublicPerm); err != nil {
+			log.Fatalf("could not write %s: %v", dest(file), err)
+		}
+		log.Printf("wrote %q", dest(file))
+	}
+
+	write(deploy.SysctlFile, deploy.Sysctl(rules.HasIPv6()))
+
+	// auth-verify writes the state of one-time codes as the user OpenVPN
+	// runs as.
+	htpasswdFile, _ := cmd.Flags().GetString("htpasswd")
+	totpState, _ := cmd.Flags().GetString("totp-state")
+	if htpasswdFile != "" {
+		var user, group string
+		if values, ok := config.Get("user"); ok && len(values) > 0 {
+			user = values[0]
+		}
+		if values, ok := config.Get("group"); ok && len(values) > 0 {
+			group = values[0]
+		}
+		write(deploy.TmpfilesFile(name), deploy.Tmpfiles(filepath.Dir(absPath("totp-state", totpState)), user, group))
+	}
+
+	for _, f := range firewallRules {
+		write(f.name, f.data)
+	}
+
+	if withUnit {
+		write(filepath.Join(deploy.UnitDir, deploy.UnitName), deploy.Unit())
+	}
+
+	log.Printf(`Your server bundle was written to: %q, install it with:`, outputDir)
+	log.Printf(`  sudo cp -r --preserve=mode %s /`, filepath.Join(outputDir, "etc"))
+	log.Printf(`  sudo sysctl --system`)
+	if htpasswdFile != "" {
+		log.Printf(`  sudo systemd-tmpfiles --create %s`, deploy.TmpfilesFile(name))
+	}
+	switch firewallType {
+	case "nftables":
+		log.Printf(`  sudo nft -f %s`, deploy.NFTablesFile(name))
+	case "iptables":
+		log.Printf(`  sudo iptables-restore --noflush %s`, deploy.IPTablesFile(name))
+		if rules.HasIPv6() {
+			log.Printf(`  sudo ip6tables-restore --noflush %s`, deploy.IP6TablesFile(name))
+		}
+	}
+	log.Printf(`  sudo systemctl enable --now %s`, deploy.ServiceName(name))
+}
+
+func init() {
+	serverConfigFlags(serverBundleCmd)
+	serverBundleCmd.Flags().StringP("output-dir", "o", "server-bundle", "Directory where the bundle is written to")
+	serverBundleCmd.Flags().String("name", "server", "Name of the server, the service is openvpn-server@{name}")
+	serverBundleCmd.Flags().String("wan-interface", "", "Interface client traffic leaves through (default: any)")
+	serverBundleCmd.Flags().String("firewall", "nftables", "Format of the firewall rules: nftables, iptables or none")
+	serverBundleCmd.Flags().Bool("unit", false, "Include openvpn-server@.service, for distributions that don't ship it")
+	splitFlags(serverBundleCmd)
+}
@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"github.com/spf13/cobra"
 	ovpncfg "github.com/xiam/openvpn-config-generator"
+	"github.com/xiam/openvpn-config-generator/lib/deploy"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
 	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
 var serverConfigCmd = &cobra.Command{
@@ -15,13 +20,14 @@ var serverConfigCmd = &cobra.Command{
 	Run:   serverConfigFn,
 }
 
-func serverConfigFn(cmd *cobra.Command, args []string) {
+// buildServerConfig creates an inline server configuration from the flags
+// added by serverConfigFlags.
+func buildServerConfig(cmd *cobra.Command) *generator.Config {
 	caCert, _ := cmd.Flags().GetString("ca")
 	cert, _ := cmd.Flags().GetString("cert")
 	key, _ := cmd.Flags().GetString("key")
 	dhKey, _ := cmd.Flags().GetString("dh")
 	tlsKey, _ := cmd.Flags().GetString("tls-crypt")
-	output, _ := cmd.Flags().GetString("output")
 
 	network, _ := cmd.Flags().GetString("network")
 	netmask, _ := cmd.Flags().GetString("netmask")
@@ -29,6 +35,22 @@ func serverConfigFn(cmd *cobra.Command, args []string) {
 	dns1, _ := cmd.Flags().GetString("dns1")
 	dns2, _ := cmd.Flags().GetString("dns2")
 
+	management, _ := cmd.Flags().GetString("management")
+	managementPasswordFile, _ := cmd.Flags().GetString("management-password-file")
+	managementClientAuth, _ := cmd.Flags().GetBool("management-client-auth")
+
+	statusFile, _ := cmd.Flags().GetString("status")
+	statusVersion, _ := cmd.Flags().GetInt("status-version")
+
+	authUserPassVerify, _ := cmd.Flags().GetString("auth-user-pass-verify")
+	htpasswdFile, _ := cmd.Flags().GetString("htpasswd")
+	verifyClientCert, _ := cmd.Flags().GetString("verify-client-cert")
+	requireTOTP, _ := cmd.Flags().GetBool("totp")
+	totpState, _ := cmd.Flags().GetString("totp-state")
+
+	clientConnectPolicy, _ := cmd.Flags().GetString("client-connect-policy")
+	clientConnectIndex, _ := cmd.Flags().GetString("client-connect-index")
+
 	checkFile(cmd, caCert, "missing CA certificate")
 	checkFile(cmd, cert, "missing certificate")
 	checkFile(cmd, key, "missing private key")
@@ -72,6 +94,77 @@ func serverConfigFn(cmd *cobra.Command, args []string) {
 	config.MustAdd("push", fmt.Sprintf("dhcp-option DNS %s", dns1))
 	config.MustAdd("push", fmt.Sprintf("dhcp-option DNS %s", dns2))
 
+	if statusFile != "" {
+		if statusVersion < 1 || statusVersion > 3 {
+			log.Fatal("--status-version must be 1, 2 or 3")
+		}
+		config.MustSet("status", statusFile)
+		config.MustSet("status-version", statusVersion)
+	}
+
+	if management != "" {
+		if err := ovpncfg.SetManagement(config, management, managementPasswordFile); err != nil {
+			log.Fatal("invalid management interface address: ", err)
+		}
+		if managementClientAuth {
+			config.MustEnable("management-client-auth")
+		}
+	} else if managementClientAuth {
+		log.Fatal("--management-client-auth requires --management")
+	}
+
+	if htpasswdFile != "" {
+		if authUserPassVerify != "" {
+			log.Fatal("--htpasswd and --auth-user-pass-verify are mutually exclusive")
+		}
+		args := []string{"auth-verify", "--htpasswd", absPath("htpasswd", htpasswdFile)}
+		if requireTOTP {
+			args = append(args, "--require-totp")
+		}
+		if totpState != "" {
+			args = append(args, "--totp-state", absPath("totp-state", totpState))
+		}
+		authUserPassVerify = selfCommand(cmd, args...)
+	} else if requireTOTP {
+		log.Fatal("--totp requires --htpasswd")
+	}
+
+	if requireTOTP {
+		// Renegotiations would ask for a code again.
+		config.MustSet("reneg-sec", 0)
+	}
+
+	if authUserPassVerify != "" {
+		config.MustSet("script-security", 2)
+		config.MustSet("auth-user-pass-verify", authUserPassVerify, "via-file")
+	}
+
+	if clientConnectPolicy != "" {
+		args := []string{"client-connect", "--policy", absPath("client-connect-policy", clientConnectPolicy)}
+		if clientConnectIndex != "" {
+			args = append(args, "--index", absPath("client-connect-index", clientConnectIndex))
+		}
+		config.MustSet("script-security", 2)
+		config.MustSet("client-connect", selfCommand(cmd, args...))
+	} else if clientConnectIndex != "" {
+		log.Fatal("--client-connect-index requires --client-connect-policy")
+	}
+
+	switch verifyClientCert {
+	case "require":
+	case "optional", "none":
+		if authUserPassVerify == "" && !managementClientAuth {
+			log.Fatalf("--verify-client-cert %s lets anyone connect without --auth-user-pass-verify or --htpasswd", verifyClientCert)
+		}
+		config.MustSet("verify-client-cert", verifyClientCert)
+		if verifyClientCert == "none" {
+			// Clients have no common name to be told apart by.
+			config.MustEnable("username-as-common-name")
+		}
+	default:
+		log.Fatalf("--verify-client-cert must be none, optional or require")
+	}
+
 	config.MustEmbed("ca", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCertBytes}))
 
 	config.MustEmbed("cert", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}))
@@ -81,23 +174,97 @@ func serverConfigFn(cmd *cobra.Command, args []string) {
 
 	config.MustEmbed("tls-crypt", tlsKeyBytes)
 
-	err = ovpncfg.WriteConfig(config, output)
+	return config
+}
+
+// selfCommand returns a command for OpenVPN scripts, like auth-verify, that
+// runs the ovpn-cfgen given to --ovpn-cfgen, this executable by default.
+func selfCommand(cmd *cobra.Command, args ...string) string {
+	exe, _ := cmd.Flags().GetString("ovpn-cfgen")
+	if exe == "" {
+		var err error
+		if exe, err = os.Executable(); err != nil {
+			log.Fatal("failed to find the path of ovpn-cfgen, use --ovpn-cfgen: ", err)
+		}
+	}
+
+	// OpenVPN splits the command on spaces.
+	args = append([]string{exe}, args...)
+	for _, arg := range args {
+		if strings.ContainsAny(arg, " \t") {
+			log.Fatalf("OpenVPN can't run commands with spaces in their arguments: %q", arg)
+		}
+	}
+
+	return strings.Join(args, " ")
+}
+
+// rejectScriptFlags fails if the flags that make OpenVPN run ovpn-cfgen are
+// given to a command whose server runs on an image without it.
+func rejectScriptFlags(cmd *cobra.Command) {
+	for _, flag := range []string{"htpasswd", "client-connect-policy"} {
+		if value, _ := cmd.Flags().GetString(flag); value != "" {
+			log.Fatalf("--%s is not supported by %s: the server image has no ovpn-cfgen to run", flag, cmd.Name())
+		}
+	}
+}
+
+// absPath returns the absolute path of the file given to flag.
+func absPath(flag string, file string) string {
+	abs, err := filepath.Abs(file)
 	if err != nil {
-		log.Fatal("could not write config file: ", err)
+		log.Fatalf("failed to resolve --%s: %v", flag, err)
 	}
+	return abs
+}
+
+func serverConfigFn(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+
+	config := buildServerConfig(cmd)
+
+	writeConfig(cmd, config, output, serverConfigSources(cmd))
 
 	log.Printf(`Your new server configuration file was written to: %q`, output)
 }
 
+// serverConfigSources maps the blocks embedded by buildServerConfig to the
+// files they're read from.
+func serverConfigSources(cmd *cobra.Command) map[string]string {
+	sources := map[string]string{}
+	for _, directive := range []string{"ca", "cert", "key", "dh", "tls-crypt"} {
+		sources[directive], _ = cmd.Flags().GetString(directive)
+	}
+	return sources
+}
+
+func serverConfigFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("ca", "r", "ca.crt", "CA certificate")
+	cmd.Flags().StringP("cert", "c", "server.crt", "Certificate")
+	cmd.Flags().StringP("key", "k", "server.key", "Private key")
+	cmd.Flags().StringP("dh", "d", "dh.pem", "Diffie-Helman key exchange file")
+	cmd.Flags().StringP("tls-crypt", "t", "key.tlsauth", "TLS Authentication key")
+	cmd.Flags().String("network", "10.9.0.0", "Network")
+	cmd.Flags().String("netmask", "255.255.0.0", "Netmask")
+	cmd.Flags().String("dns1", "8.8.8.8", "DNS1")
+	cmd.Flags().String("dns2", "8.8.4.4", "DNS2")
+	cmd.Flags().String("management", "", "Management interface address, either a unix socket path (e.g.: unix:/run/openvpn/server.sock) or a loopback host:port")
+	cmd.Flags().String("management-password-file", "", "File holding the management interface password, required for TCP")
+	cmd.Flags().Bool("management-client-auth", false, "Let the management interface authenticate clients")
+	cmd.Flags().String("status", "", "File where OpenVPN periodically writes the list of connected clients")
+	cmd.Flags().Int("status-version", 2, "Format of the status file (1, 2 or 3)")
+	cmd.Flags().String("auth-user-pass-verify", "", "Command that checks the username and password of clients, it gets them in a file (via-file)")
+	cmd.Flags().String("htpasswd", "", "Check the username and password of clients against this htpasswd file with auth-verify")
+	cmd.Flags().String("verify-client-cert", "require", "Whether clients need a certificate: none, optional or require")
+	cmd.Flags().Bool("totp", false, "With --htpasswd, require every user to send a one-time code")
+	cmd.Flags().String("totp-state", deploy.TOTPStateFile, "With --htpasswd, file where the one-time code last accepted from every user is kept, its directory must be writable by OpenVPN")
+	cmd.Flags().String("client-connect-policy", "", "Give clients the static addresses and options of this policy file when they connect")
+	cmd.Flags().String("client-connect-index", "", "With --client-connect-policy, reject clients that are not valid in this index.txt file")
+	cmd.Flags().String("ovpn-cfgen", "", "Path of ovpn-cfgen on the server, run by --htpasswd and --client-connect-policy (default: this executable)")
+}
+
 func init() {
-	serverConfigCmd.Flags().StringP("ca", "r", "ca.crt", "CA certificate")
-	serverConfigCmd.Flags().StringP("cert", "c", "server.crt", "Certificate")
-	serverConfigCmd.Flags().StringP("key", "k", "server.key", "Private key")
-	serverConfigCmd.Flags().StringP("dh", "d", "dh.pem", "Diffie-Helman key exchange file")
-	serverConfigCmd.Flags().StringP("tls-crypt", "t", "key.tlsauth", "TLS Authentication key")
-	serverConfigCmd.Flags().String("network", "10.9.0.0", "Network")
-	serverConfigCmd.Flags().String("netmask", "255.255.0.0", "Netmask")
-	serverConfigCmd.Flags().String("dns1", "8.8.8.8", "DNS1")
-	serverConfigCmd.Flags().String("dns2", "8.8.4.4", "DNS2")
+	serverConfigFlags(serverConfigCmd)
 	serverConfigCmd.Flags().StringP("output", "o", "server.conf", "Output file")
+	splitFlags(serverConfigCmd)
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/api"
+	"github.com/xiam/openvpn-config-generator/lib/links"
+	"github.com/xiam/openvpn-config-generator/lib/pki"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share NAME [OPTIONS]",
+	Short: "Publish a client profile behind a one-time download link",
+	Long: `Publish a client profile behind a one-time download link.
+
+The profile of client NAME is generated from the work directory and stored
+in its links directory, the printed link can be used only once and expires
+after --ttl. Links are served by the serve command; use --listen to serve the
+link from this command instead, it exits as soon as the profile is
+downloaded or the link expires.`,
+	Args: cobra.ExactArgs(1),
+	Run:  shareFn,
+}
+
+func shareFn(cmd *cobra.Command, args []string) {
+	workdir, _ := cmd.Flags().GetString("workdir")
+	remote, _ := cmd.Flags().GetString("remote")
+	ttl, _ := cmd.Flags().GetDuration("ttl")
+	baseURL, _ := cmd.Flags().GetString("base-url")
+	listen, _ := cmd.Flags().GetString("listen")
+
+	name := args[0]
+
+	if remote == "" {
+		log.Fatal("missing required --remote parameter")
+	}
+
+	if baseURL == "" {
+		if listen == "" {
+			log.Fatal("missing required --base-url parameter")
+		}
+		baseURL = "http://" + listen
+	}
+
+	store, err := pki.Open(workdir)
+	if err != nil {
+		log.Fatal("failed to open work directory: ", err)
+	}
+
+	config, err := store.ClientConfig(name, remote)
+	if err != nil {
+		log.Fatal("failed to build client profile: ", err)
+	}
+
+	profile, err := config.Compile()
+	if err != nil {
+		log.Fatal("failed to build client profile: ", err)
+	}
+
+	linkStore, err := links.Open(workdir)
+	if err != nil {
+		log.Fatal("failed to open links directory: ", err)
+	}
+
+	token, expires, err := linkStore.Publish(name, profile, ttl)
+	if err != nil {
+		log.Fatal("failed to publish profile: ", err)
+	}
+
+	url := strings.TrimSuffix(baseURL, "/") + path.Join(api.DownloadPrefix, token)
+	fmt.Println(url)
+	writeQR(cmd, []byte(url))
+	log.Printf("The link expires at %v", expires.Local().Format(time.RFC1123))
+
+	if listen == "" {
+		return
+	}
+
+	srv := &http.Server{Addr: listen}
+	done := make(chan struct{})
+	var once sync.Once
+
+	handler := linkStore.Handler()
+	srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+		if !linkStore.Pending(token) {
+			once.Do(func() { close(done) })
+		}
+	})
+
+	go func() {
+		select {
+		case <-done:
+		case <-time.After(time.Until(expires)):
+			log.Printf("The link expired before being used")
+		}
+		srv.Shutdown(context.Background())
+	}()
+
+	log.Printf("Serving link on %s", listen)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
+
+func init() {
+	shareCmd.Flags().String("workdir", ".", "Work directory")
+	shareCmd.Flags().String("remote", "", "Address of the remote OpenVPN server")
+	shareCmd.Flags().Duration("ttl", links.DefaultTTL, "How long the link stays valid")
+	shareCmd.Flags().String("base-url", "", "Public URL of the server serving the link")
+	shareCmd.Flags().String("listen", "", "Serve the link on this address until it's used")
+	qrFlags(shareCmd)
+}
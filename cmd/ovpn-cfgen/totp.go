@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/htpasswd"
+	"github.com/xiam/openvpn-config-generator/lib/totp"
+	"log"
+)
+
+var totpCmd = &cobra.Command{
+	Use:   "totp [OPTIONS] FILE USERNAME",
+	Short: "Enroll a user of an htpasswd file in TOTP and print its secret",
+	Long: `Enroll a user of an htpasswd file in TOTP, a secret is created the first
+time. The secret and the otpauth:// URL authenticator apps enroll from are
+printed, use --qr to show the URL as a QR code.`,
+	Args: cobra.ExactArgs(2),
+	Run:  totpFn,
+}
+
+func totpFn(cmd *cobra.Command, args []string) {
+	file, username := args[0], args[1]
+
+	issuer, _ := cmd.Flags().GetString("issuer")
+	reset, _ := cmd.Flags().GetBool("reset")
+	remove, _ := cmd.Flags().GetBool("remove")
+
+	users, err := htpasswd.Load(file)
+	if err != nil {
+		log.Fatalf("failed to read %q: %v", file, err)
+	}
+
+	if remove {
+		if err := users.SetTOTP(username, ""); err != nil {
+			log.Fatalf("%q: %v", username, err)
+		}
+		writeHtpasswd(file, users)
+		log.Printf("User %q no longer needs a one-time code", username)
+		return
+	}
+
+	secret := users.TOTP(username)
+	if secret == "" || reset {
+		if secret, err = totp.GenerateSecret(); err != nil {
+			log.Fatal("failed to create TOTP secret: ", err)
+		}
+		if err := users.SetTOTP(username, secret); err != nil {
+			log.Fatalf("%q: %v (add the user with htpasswd first)", username, err)
+		}
+		writeHtpasswd(file, users)
+		log.Printf("User %q was enrolled in TOTP", username)
+	}
+
+	url := totp.URL(issuer, username, secret)
+
+	fmt.Printf("Secret: %s\n", secret)
+	fmt.Printf("URL:    %s\n", url)
+
+	writeQR(cmd, []byte(url))
+}
+
+func init() {
+	totpCmd.Flags().String("issuer", "OpenVPN", "Name authenticator apps show next to the code")
+	totpCmd.Flags().Bool("reset", false, "Replace the secret of a user that is already enrolled")
+	totpCmd.Flags().Bool("remove", false, "Stop asking the user for a one-time code")
+	qrFlags(totpCmd)
+}
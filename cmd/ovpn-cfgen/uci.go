@@ -0,0 +1,81 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	ovpncfg "github.com/xiam/openvpn-config-generator"
+	"github.com/xiam/openvpn-config-generator/lib/output"
+	"github.com/xiam/openvpn-config-generator/lib/uci"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var uciCmd = &cobra.Command{
+	Use:   "uci [OPTIONS] FILE",
+	Short: "Convert a server or client configuration file into OpenWrt's UCI syntax",
+	Long: `Convert a server or client configuration file into OpenWrt's UCI syntax.
+
+The openvpn section is written to {output-dir}/openvpn along with the
+certificates and keys embedded in FILE. The section references them by
+absolute path, under --cert-dir, so copy them there and append the section
+to /etc/config/openvpn on the router:
+
+  scp ca.crt my-router.crt my-router.key ta.key root@router:/etc/openvpn/my-router/
+  cat openvpn | ssh root@router 'cat >> /etc/config/openvpn'
+  ssh root@router /etc/init.d/openvpn restart`,
+	Args: cobra.ExactArgs(1),
+	Run:  uciFn,
+}
+
+func uciFn(cmd *cobra.Command, args []string) {
+	name, _ := cmd.Flags().GetString("name")
+	outputDir, _ := cmd.Flags().GetString("output-dir")
+	certDir, _ := cmd.Flags().GetString("cert-dir")
+	disabled, _ := cmd.Flags().GetBool("disabled")
+
+	config, err := readConfigFile(args[0])
+	if err != nil {
+		log.Fatalf("failed to read %q: %v", args[0], err)
+	}
+
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+	}
+
+	if certDir == "" {
+		certDir = path.Join("/etc/openvpn", name)
+	}
+
+	if config.Has("client") {
+		adaptClientConfig(config, string(ovpncfg.ClientOpenWrt))
+	}
+
+	section, err := uci.Convert(config, uci.Options{
+		Name:     name,
+		Dir:      certDir,
+		Disabled: disabled,
+	})
+	if err != nil {
+		log.Fatal("failed to convert configuration: ", err)
+	}
+
+	out := newWriter(cmd)
+
+	writeFiles(out, outputDir, section.Files)
+
+	file := filepath.Join(outputDir, uci.ConfigName)
+	if err := out.WriteFile(file, section.Config, output.PublicPerm); err != nil {
+		log.Fatal("could not write UCI configuration: ", err)
+	}
+
+	log.Printf(`Your UCI configuration was written to: %q, it expects the files above in %q`, file, certDir)
+}
+
+func init() {
+	uciCmd.Flags().String("name", "", "Name of the openvpn section (default: name of FILE)")
+	uciCmd.Flags().String("output-dir", ".", "Directory where the UCI configuration, certificates and keys are written to")
+	uciCmd.Flags().String("cert-dir", "", "Absolute path the certificates and keys are installed to on the router (default: /etc/openvpn/{name})")
+	uciCmd.Flags().Bool("disabled", false, "Create the section disabled")
+	uciCmd.Flags().Bool("force", false, "Overwrite existing private keys")
+}
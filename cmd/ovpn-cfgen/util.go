@@ -1,12 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/archive"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+	"github.com/xiam/openvpn-config-generator/lib/output"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 func checkFile(cmd *cobra.Command, file string, message string) {
@@ -27,3 +35,182 @@ func readPemFile(file string) ([]byte, error) {
 	pemBody, _ := pem.Decode(buf)
 	return pemBody.Bytes, nil
 }
+
+func readConfigFile(file string) (*generator.Config, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return generator.Parse(buf)
+}
+
+func writeJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatal("failed to encode JSON: ", err)
+	}
+}
+
+// readSecret reads a secret from the file given by flag, or from the env
+// environment variable if the flag is not set.
+func readSecret(cmd *cobra.Command, flag string, env string) string {
+	file, _ := cmd.Flags().GetString(flag)
+	if file == "" {
+		return os.Getenv(env)
+	}
+
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatalf("failed to read --%s: %v", flag, err)
+	}
+
+	return strings.TrimSpace(string(buf))
+}
+
+// newWriter returns a writer that honors the --force flag.
+func newWriter(cmd *cobra.Command) *output.Writer {
+	force, _ := cmd.Flags().GetBool("force")
+
+	out := output.New(output.Disk)
+	out.Force = force
+
+	return out
+}
+
+// splitFlags adds the flags used by writeConfig.
+func splitFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("split", false, "Reference certificates and keys as separate files instead of embedding them")
+	cmd.Flags().Bool("link", false, "With --split, symlink the original files next to the output instead of copying them")
+	cmd.Flags().Bool("force", false, "With --split, overwrite existing private keys next to the output")
+}
+
+// writeConfig writes config to file. With --split every inline block is
+// replaced by a reference to a file next to file, sources maps directives to
+// the files their blocks were read from.
+func writeConfig(cmd *cobra.Command, config *generator.Config, file string, sources map[string]string) {
+	split, _ := cmd.Flags().GetBool("split")
+	link, _ := cmd.Flags().GetBool("link")
+
+	out := newWriter(cmd)
+
+	if !split {
+		if link {
+			log.Fatal("--link requires --split")
+		}
+		if err := out.WriteConfig(config, file); err != nil {
+			log.Fatal("could not write config file: ", err)
+		}
+		return
+	}
+
+	dir := filepath.Dir(file)
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+
+	config, files, err := config.Split(func(directive string) string {
+		if source, ok := sources[directive]; ok {
+			return filepath.Base(source)
+		}
+		return archive.FileName(directive, name)
+	})
+	if err != nil {
+		log.Fatal("could not split config file: ", err)
+	}
+
+	copies := []generator.File{}
+	for _, f := range files {
+		dest := filepath.Join(dir, f.Name)
+		source := sources[f.Directive]
+
+		if source != "" && sameFile(source, dest) {
+			continue
+		}
+
+		if link && source != "" {
+			if buf, err := ioutil.ReadFile(dest); err == nil && bytes.Equal(buf, f.Data) {
+				continue
+			}
+			if err := symlink(source, dest, out.Force || !output.IsSecret(f.Directive)); err != nil {
+				log.Fatalf("could not link %s: %v", dest, err)
+			}
+			log.Printf("linked %q to %q", dest, source)
+			continue
+		}
+
+		copies = append(copies, f)
+	}
+
+	writeFiles(out, dir, copies)
+
+	if err := out.WriteConfig(config, file); err != nil {
+		log.Fatal("could not write config file: ", err)
+	}
+}
+
+// writeFiles writes the blocks moved out of a configuration to dir, files
+// that already hold the same contents are left alone.
+func writeFiles(out *output.Writer, dir string, files []generator.File) {
+	for _, f := range files {
+		dest := filepath.Join(dir, f.Name)
+		if buf, err := ioutil.ReadFile(dest); err == nil && bytes.Equal(buf, f.Data) {
+			continue
+		}
+
+		var err error
+		if output.IsSecret(f.Directive) {
+			err = out.WriteSecret(dest, f.Data)
+		} else {
+			err = out.WriteFile(dest, f.Data, output.PublicPerm)
+		}
+		if err != nil {
+			log.Fatalf("could not write %s (use --force to overwrite private keys): %v", dest, err)
+		}
+		log.Printf("wrote %q", dest)
+	}
+}
+
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fa, fb)
+}
+
+func symlink(source, dest string, overwrite bool) error {
+	source, err := filepath.Abs(source)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(dest); err == nil {
+		if !overwrite {
+			return output.ErrExists
+		}
+		if err := os.Remove(dest); err != nil {
+			return err
+		}
+	}
+
+	return os.Symlink(source, dest)
+}
+
+// wrapBase64 encodes buf in base64 lines of 64 characters, the format used
+// by inline pkcs12 blocks.
+func wrapBase64(buf []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(buf)
+
+	var res bytes.Buffer
+	for len(encoded) > 64 {
+		res.WriteString(encoded[:64] + "\n")
+		encoded = encoded[64:]
+	}
+	res.WriteString(encoded)
+
+	return res.Bytes()
+}
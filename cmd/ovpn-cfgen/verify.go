@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/xiam/openvpn-config-generator/lib/verify"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [OPTIONS] FILE",
+	Short: "Check that the certificates and keys of a configuration file belong together",
+	Args:  cobra.ExactArgs(1),
+	Run:   verifyFn,
+}
+
+func verifyFn(cmd *cobra.Command, args []string) {
+	format, _ := cmd.Flags().GetString("format")
+	role, _ := cmd.Flags().GetString("role")
+	crlFile, _ := cmd.Flags().GetString("crl")
+
+	config, err := readConfigFile(args[0])
+	if err != nil {
+		log.Fatalf("failed to read %q: %v", args[0], err)
+	}
+
+	opts := &verify.Options{}
+
+	switch verify.Role(role) {
+	case "", verify.RoleClient, verify.RoleServer:
+		opts.Role = verify.Role(role)
+	default:
+		log.Fatalf("unknown role %q", role)
+	}
+
+	if crlFile != "" {
+		if opts.CRL, err = ioutil.ReadFile(crlFile); err != nil {
+			log.Fatal("failed to read CRL: ", err)
+		}
+	}
+
+	report, err := verify.Config(config, opts)
+	if err != nil {
+		log.Fatal("failed to verify configuration: ", err)
+	}
+
+	switch format {
+	case "json":
+		writeJSON(report)
+	case "text":
+		for _, check := range report.Checks {
+			fmt.Printf("%-4s  %-8s  %s\n", strings.ToUpper(string(check.Status)), check.Name, check.Message)
+		}
+	default:
+		log.Fatalf("unknown output format %q", format)
+	}
+
+	if !report.OK() {
+		os.Exit(1)
+	}
+	if !report.Verified() {
+		log.Print("warning: the certificate was not verified")
+		os.Exit(2)
+	}
+}
+
+func init() {
+	verifyCmd.Flags().StringP("format", "f", "text", "Output format (text or json)")
+	verifyCmd.Flags().String("role", "", "Role of the configuration file (client or server), detected if empty")
+	verifyCmd.Flags().String("crl", "", "Certificate revocation list, overrides any inline <crl-verify> block")
+}
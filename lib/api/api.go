@@ -0,0 +1,295 @@
+// Package api exposes client provisioning over HTTP.
+//
+// Every request must carry an "Authorization: Bearer {token}" header. The
+// following endpoints are available:
+//
+//	GET    /api/clients              list clients
+//	POST   /api/clients              create a client, body: {"name": "..."}
+//	GET    /api/clients/{name}       get a client
+//	DELETE /api/clients/{name}       revoke a client
+//	GET    /api/clients/{name}/ovpn  download the client's .ovpn profile
+//	POST   /api/clients/{name}/link  create a one-time download link
+//	GET    /api/crl                  download the certificate revocation list
+//
+// One-time links are kept in the work directory and served by the handler
+// returned by Server.Downloads, which doesn't require authentication.
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xiam/openvpn-config-generator/lib/links"
+	"github.com/xiam/openvpn-config-generator/lib/pki"
+)
+
+const (
+	// Prefix is the path under which the API is served.
+	Prefix = "/api/"
+
+	// DownloadPrefix is the path under which one-time links are served.
+	DownloadPrefix = "/download/"
+)
+
+// Options configure the API server.
+type Options struct {
+	// Token is the secret clients must present, requests are rejected if
+	// it's empty.
+	Token string
+
+	// Remote is the address of the OpenVPN server written to client
+	// profiles.
+	Remote string
+
+	// LinkTTL is how long one-time links stay valid, links.DefaultTTL is
+	// used if zero.
+	LinkTTL time.Duration
+}
+
+// Server is an http.Handler serving the API.
+type Server struct {
+	store *pki.Store
+	links *links.Store
+	opts  Options
+}
+
+// New returns an API server backed by store, one-time links are kept in the
+// same work directory.
+func New(store *pki.Store, opts Options) (*Server, error) {
+	links, err := links.Open(store.Dir())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		store: store,
+		links: links,
+		opts:  opts,
+	}, nil
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// writeStoreError maps errors returned by the store to HTTP status codes.
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch err {
+	case pki.ErrNotFound:
+		writeError(w, http.StatusNotFound, err)
+	case pki.ErrExists, pki.ErrRevoked:
+		writeError(w, http.StatusConflict, err)
+	default:
+		log.Printf("internal error: %v", err)
+		writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.opts.Token == "" {
+		return false
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) == 1
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ovpn-cfgen"`)
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, Prefix), "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "clients":
+		switch r.Method {
+		case http.MethodGet:
+			s.listClients(w, r)
+		case http.MethodPost:
+			s.createClient(w, r)
+		default:
+			methodNotAllowed(w, "GET, POST")
+		}
+	case len(parts) == 2 && parts[0] == "clients":
+		switch r.Method {
+		case http.MethodGet:
+			s.getClient(w, r, parts[1])
+		case http.MethodDelete:
+			s.revokeClient(w, r, parts[1])
+		default:
+			methodNotAllowed(w, "GET, DELETE")
+		}
+	case len(parts) == 3 && parts[0] == "clients" && parts[2] == "ovpn":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, "GET")
+			return
+		}
+		s.downloadProfile(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "clients" && parts[2] == "link":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, "POST")
+			return
+		}
+		s.createLink(w, r, parts[1])
+	case len(parts) == 1 && parts[0] == "crl":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, "GET")
+			return
+		}
+		s.downloadCRL(w, r)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+func (s *Server) listClients(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.store.List()
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+type createClientRequest struct {
+	Name string `json:"name"`
+}
+
+func (s *Server) createClient(w http.ResponseWriter, r *http.Request) {
+	var req createClientRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %v", err))
+		return
+	}
+
+	if err := pki.ValidateName(req.Name); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	entry, err := s.store.CreateClient(req.Name)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	log.Printf("api: created client %q", entry.CommonName)
+	writeJSON(w, http.StatusCreated, entry)
+}
+
+func (s *Server) getClient(w http.ResponseWriter, r *http.Request, name string) {
+	entry, err := s.store.Get(name)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entry)
+}
+
+func (s *Server) revokeClient(w http.ResponseWriter, r *http.Request, name string) {
+	entry, err := s.store.Revoke(name)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	log.Printf("api: revoked client %q", entry.CommonName)
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// Profile compiles the .ovpn profile of a client.
+func (s *Server) Profile(name string) ([]byte, error) {
+	config, err := s.store.ClientConfig(name, s.opts.Remote)
+	if err != nil {
+		return nil, err
+	}
+	return config.Compile()
+}
+
+func (s *Server) downloadProfile(w http.ResponseWriter, r *http.Request, name string) {
+	buf, err := s.Profile(name)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	ServeProfile(w, name, buf)
+}
+
+// ServeProfile writes buf as a downloadable .ovpn file.
+func ServeProfile(w http.ResponseWriter, name string, buf []byte) {
+	w.Header().Set("Content-Type", "application/x-openvpn-profile")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.ovpn"`, name))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf)
+}
+
+func (s *Server) downloadCRL(w http.ResponseWriter, r *http.Request) {
+	buf, err := s.store.CRL()
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Write(buf)
+}
+
+type linkResponse struct {
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+func (s *Server) createLink(w http.ResponseWriter, r *http.Request, name string) {
+	buf, err := s.Profile(name)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	token, expires, err := s.links.Publish(name, buf, s.opts.LinkTTL)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	log.Printf("api: created download link for client %q", name)
+	writeJSON(w, http.StatusCreated, linkResponse{URL: DownloadPrefix + token, Expires: expires})
+}
+
+// Downloads returns a handler that serves one-time links.
+func (s *Server) Downloads() http.Handler {
+	return s.links.Handler()
+}
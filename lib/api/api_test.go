@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	ovpncfg "github.com/xiam/openvpn-config-generator"
+	"github.com/xiam/openvpn-config-generator/lib/certtool"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+	"github.com/xiam/openvpn-config-generator/lib/pki"
+)
+
+const testToken = "s3cr3t"
+
+func newTestServer(t *testing.T) (*httptest.Server, func()) {
+	dir, err := ioutil.TempDir("", "api")
+	assert.NoError(t, err)
+
+	caCert, caKey, err := certtool.BuildCA()
+	assert.NoError(t, err)
+
+	assert.NoError(t, ovpncfg.WriteCert(caCert, filepath.Join(dir, "ca.crt")))
+	assert.NoError(t, ovpncfg.WriteKey(caKey, filepath.Join(dir, "ca.key")))
+
+	staticKey, err := ovpncfg.GenOpenVPNStaticKey()
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, pki.TLSCryptKeyFile), staticKey, 0600))
+
+	store, err := pki.Open(dir)
+	assert.NoError(t, err)
+
+	server, err := New(store, Options{Token: testToken, Remote: "vpn.example.org"})
+	assert.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(Prefix, server)
+	mux.Handle(DownloadPrefix, server.Downloads())
+
+	ts := httptest.NewServer(mux)
+
+	return ts, func() {
+		ts.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func do(t *testing.T, method string, url string, body string, token string) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.NoError(t, err)
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+
+	buf, err := ioutil.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	return res, buf
+}
+
+func TestAuthentication(t *testing.T) {
+	ts, cleanup := newTestServer(t)
+	defer cleanup()
+
+	res, _ := do(t, "GET", ts.URL+"/api/clients", "", "")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	res, _ = do(t, "GET", ts.URL+"/api/clients", "", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	res, _ = do(t, "GET", ts.URL+"/api/clients", "", testToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestProvisioning(t *testing.T) {
+	ts, cleanup := newTestServer(t)
+	defer cleanup()
+
+	res, body := do(t, "POST", ts.URL+"/api/clients", `{"name": "laptop"}`, testToken)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+	var entry pki.Entry
+	assert.NoError(t, json.Unmarshal(body, &entry))
+	assert.Equal(t, "laptop", entry.CommonName)
+	assert.Equal(t, pki.StatusValid, entry.Status)
+
+	res, _ = do(t, "POST", ts.URL+"/api/clients", `{"name": "laptop"}`, testToken)
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+	res, _ = do(t, "POST", ts.URL+"/api/clients", `{"name": "../laptop"}`, testToken)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res, _ = do(t, "POST", ts.URL+"/api/clients", `nonsense`, testToken)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res, body = do(t, "GET", ts.URL+"/api/clients", "", testToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	var entries []pki.Entry
+	assert.NoError(t, json.Unmarshal(body, &entries))
+	assert.Equal(t, 1, len(entries))
+
+	res, body = do(t, "GET", ts.URL+"/api/clients/laptop/ovpn", "", testToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, res.Header.Get("Content-Disposition"), `filename="laptop.ovpn"`)
+
+	config, err := generator.Parse(body)
+	assert.NoError(t, err)
+	remote, _ := config.Get("remote")
+	assert.Equal(t, []string{"vpn.example.org"}, remote)
+	_, ok := config.Embedded("key")
+	assert.True(t, ok)
+
+	res, _ = do(t, "GET", ts.URL+"/api/clients/desktop", "", testToken)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res, body = do(t, "DELETE", ts.URL+"/api/clients/laptop", "", testToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.NoError(t, json.Unmarshal(body, &entry))
+	assert.Equal(t, pki.StatusRevoked, entry.Status)
+
+	res, _ = do(t, "GET", ts.URL+"/api/clients/laptop/ovpn", "", testToken)
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+	res, body = do(t, "GET", ts.URL+"/api/crl", "", testToken)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, string(body), "-----BEGIN X509 CRL-----")
+
+	res, _ = do(t, "PUT", ts.URL+"/api/clients", "", testToken)
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+}
+
+func TestDownloadLink(t *testing.T) {
+	ts, cleanup := newTestServer(t)
+	defer cleanup()
+
+	res, _ := do(t, "POST", ts.URL+"/api/clients/laptop/link", "", testToken)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res, _ = do(t, "POST", ts.URL+"/api/clients", `{"name": "laptop"}`, testToken)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+	res, body := do(t, "POST", ts.URL+"/api/clients/laptop/link", "", testToken)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+	var link linkResponse
+	assert.NoError(t, json.Unmarshal(body, &link))
+	assert.True(t, strings.HasPrefix(link.URL, DownloadPrefix))
+	assert.True(t, link.Expires.After(time.Now()))
+
+	res, body = do(t, "GET", ts.URL+link.URL, "", "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, string(body), "<key>")
+
+	res, _ = do(t, "GET", ts.URL+link.URL, "", "")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
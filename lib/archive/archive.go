@@ -0,0 +1,193 @@
+// Package archive packs client profiles into zip and tar.gz bundles.
+//
+// A bundle holds either the inline profile or a split profile, where every
+// inline block is moved to its own file and the profile references it by
+// name. Split bundles suit clients that can't read inline profiles, like
+// some routers and old Windows GUIs.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+	"github.com/xiam/openvpn-config-generator/lib/output"
+)
+
+// Format of an archive.
+type Format string
+
+const (
+	Zip   Format = "zip"
+	TarGz Format = "tar.gz"
+)
+
+// ParseFormat returns the Format named s.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "zip":
+		return Zip, nil
+	case "tar.gz", "tgz":
+		return TarGz, nil
+	}
+	return "", fmt.Errorf("unknown archive format %q", s)
+}
+
+// FormatFromName guesses the Format from the extension of a file name.
+func FormatFromName(name string) (Format, error) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return Zip, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGz, nil
+	}
+	return "", fmt.Errorf("can't guess archive format of %q", name)
+}
+
+// File is an entry of an archive.
+type File struct {
+	Name string
+	Data []byte
+	Mode os.FileMode
+}
+
+// fileNames maps inline blocks to the files they're moved to in split
+// bundles, "%s" is replaced by the name of the profile.
+var fileNames = map[string]string{
+	"ca":             "ca.crt",
+	"cert":           "%s.crt",
+	"key":            "%s.key",
+	"pkcs12":         "%s.p12",
+	"tls-crypt":      "ta.key",
+	"tls-auth":       "ta.key",
+	"secret":         "static.key",
+	"dh":             "dh.pem",
+	"extra-certs":    "extra-certs.crt",
+	"crl-verify":     "crl.pem",
+	"auth-user-pass": "%s.auth",
+}
+
+// FileName returns the name of the file the <directive> block of profile
+// name is written to in split bundles.
+func FileName(directive string, name string) string {
+	if format, ok := fileNames[directive]; ok {
+		if strings.Contains(format, "%s") {
+			return fmt.Sprintf(format, name)
+		}
+		return format
+	}
+	return directive + ".pem"
+}
+
+func fileMode(secret bool) os.FileMode {
+	if secret {
+		return output.PrivatePerm
+	}
+	return output.PublicPerm
+}
+
+// Files returns the files of a bundle for profile name. If split is false
+// the bundle only holds {name}.ovpn, otherwise every inline block of config
+// is moved to its own file.
+func Files(config *generator.Config, name string, split bool) ([]File, error) {
+	if !split {
+		buf, err := config.Compile()
+		if err != nil {
+			return nil, err
+		}
+		return []File{{Name: name + ".ovpn", Data: buf, Mode: fileMode(output.HasSecrets(config))}}, nil
+	}
+
+	profile, blocks, err := config.Split(func(directive string) string {
+		return FileName(directive, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := []File{}
+	for _, block := range blocks {
+		files = append(files, File{
+			Name: block.Name,
+			Data: block.Data,
+			Mode: fileMode(output.IsSecret(block.Directive)),
+		})
+	}
+
+	buf, err := profile.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]File{{Name: name + ".ovpn", Data: buf, Mode: output.PublicPerm}}, files...), nil
+}
+
+// Write packs files into w.
+func Write(w io.Writer, format Format, files []File) error {
+	switch format {
+	case Zip:
+		return writeZip(w, files)
+	case TarGz:
+		return writeTarGz(w, files)
+	}
+	return fmt.Errorf("unknown archive format %q", format)
+}
+
+func writeZip(w io.Writer, files []File) error {
+	zw := zip.NewWriter(w)
+
+	now := time.Now()
+	for _, file := range files {
+		header := &zip.FileHeader{
+			Name:     file.Name,
+			Method:   zip.Deflate,
+			Modified: now,
+		}
+		header.SetMode(file.Mode)
+
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(file.Data); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeTarGz(w io.Writer, files []File) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	now := time.Now()
+	for _, file := range files {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.Name,
+			Mode:     int64(file.Mode.Perm()),
+			Size:     int64(len(file.Data)),
+			ModTime:  now,
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.Data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
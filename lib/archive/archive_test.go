@@ -0,0 +1,114 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+	"github.com/xiam/openvpn-config-generator/lib/output"
+)
+
+func newTestConfig() *generator.Config {
+	config := generator.New()
+	config.MustEnable("client")
+	config.MustSet("remote", "vpn.example.org")
+	config.MustEmbed("ca", []byte("CA"))
+	config.MustEmbed("cert", []byte("CERT"))
+	config.MustEmbed("key", []byte("KEY"))
+	config.MustEmbed("tls-crypt", []byte("STATIC"))
+	return config
+}
+
+func TestFiles(t *testing.T) {
+	config := newTestConfig()
+
+	files, err := Files(config, "laptop", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(files))
+	assert.Equal(t, "laptop.ovpn", files[0].Name)
+	assert.Equal(t, output.PrivatePerm, files[0].Mode)
+	assert.Contains(t, string(files[0].Data), "<key>")
+
+	files, err = Files(config, "laptop", true)
+	assert.NoError(t, err)
+
+	names := []string{}
+	modes := map[string]interface{}{}
+	for _, file := range files {
+		names = append(names, file.Name)
+		modes[file.Name] = file.Mode
+	}
+	assert.Equal(t, []string{"laptop.ovpn", "ca.crt", "laptop.crt", "laptop.key", "ta.key"}, names)
+	assert.Equal(t, output.PrivatePerm, modes["laptop.key"])
+	assert.Equal(t, output.PrivatePerm, modes["ta.key"])
+	assert.Equal(t, output.PublicPerm, modes["ca.crt"])
+
+	assert.Equal(t, "client\nremote \"vpn.example.org\"\nca \"ca.crt\"\ncert \"laptop.crt\"\nkey \"laptop.key\"\ntls-crypt \"ta.key\"", string(files[0].Data))
+	assert.Equal(t, "KEY\n", string(files[3].Data))
+
+	// The original configuration is left untouched.
+	_, ok := config.Embedded("key")
+	assert.True(t, ok)
+}
+
+func TestWrite(t *testing.T) {
+	files, err := Files(newTestConfig(), "laptop", true)
+	assert.NoError(t, err)
+
+	{
+		var buf bytes.Buffer
+		assert.NoError(t, Write(&buf, Zip, files))
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		assert.NoError(t, err)
+		assert.Equal(t, len(files), len(zr.File))
+
+		fp, err := zr.File[3].Open()
+		assert.NoError(t, err)
+		data, err := ioutil.ReadAll(fp)
+		assert.NoError(t, err)
+		assert.Equal(t, "KEY\n", string(data))
+		assert.Equal(t, output.PrivatePerm, zr.File[3].Mode().Perm())
+	}
+
+	{
+		var buf bytes.Buffer
+		assert.NoError(t, Write(&buf, TarGz, files))
+
+		gr, err := gzip.NewReader(&buf)
+		assert.NoError(t, err)
+
+		tr := tar.NewReader(gr)
+		n := 0
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, files[n].Name, header.Name)
+			assert.Equal(t, int64(files[n].Mode), header.Mode)
+			n++
+		}
+		assert.Equal(t, len(files), n)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	format, err := FormatFromName("laptop.tar.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, TarGz, format)
+
+	format, err = ParseFormat("ZIP")
+	assert.NoError(t, err)
+	assert.Equal(t, Zip, format)
+
+	_, err = FormatFromName("laptop.rar")
+	assert.Error(t, err)
+}
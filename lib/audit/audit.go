@@ -0,0 +1,320 @@
+// Package audit inspects OpenVPN configurations for insecure settings.
+package audit
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xiam/openvpn-config-generator/lib/certtool"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+// Severity tells how serious a finding is.
+type Severity uint
+
+const (
+	SeverityInfo Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"info", "low", "medium", "high", "critical"}
+
+func (s Severity) String() string {
+	if int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return fmt.Sprintf("severity(%d)", s)
+}
+
+// MarshalJSON encodes the severity by name.
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// ParseSeverity returns the severity with the given name.
+func ParseSeverity(name string) (Severity, error) {
+	for i := range severityNames {
+		if strings.EqualFold(name, severityNames[i]) {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q", name)
+}
+
+// Finding is a single issue found in a configuration.
+type Finding struct {
+	ID        string   `json:"id"`
+	Severity  Severity `json:"severity"`
+	Directive string   `json:"directive,omitempty"`
+	Message   string   `json:"message"`
+}
+
+// Options tune the checks performed by Check.
+type Options struct {
+	// Now is the point in time certificates are checked against, the
+	// current time is used if zero.
+	Now time.Time
+
+	// ExpiryWindow is how close to expiration a certificate can get
+	// before being reported.
+	ExpiryWindow time.Duration
+
+	// MinRSABits is the smallest acceptable RSA modulus.
+	MinRSABits int
+}
+
+// DefaultOptions are used when Check is given nil options.
+var DefaultOptions = Options{
+	ExpiryWindow: 30 * 24 * time.Hour,
+	MinRSABits:   2048,
+}
+
+// Check inspects cfg and its embedded certificates and returns the findings
+// sorted from most to least severe.
+func Check(cfg *generator.Config, opts *Options) []Finding {
+	if opts == nil {
+		opts = &DefaultOptions
+	}
+
+	a := &auditor{cfg: cfg, opts: *opts, findings: []Finding{}}
+	if a.opts.Now.IsZero() {
+		a.opts.Now = time.Now()
+	}
+
+	a.checkCompression()
+	a.checkControlChannel()
+	a.checkCiphers()
+	a.checkRemoteCert()
+	a.checkCertificates()
+	a.checkDH()
+
+	sort.SliceStable(a.findings, func(i, j int) bool {
+		return a.findings[i].Severity > a.findings[j].Severity
+	})
+
+	return a.findings
+}
+
+// Max returns the highest severity among findings.
+func Max(findings []Finding) (Severity, bool) {
+	if len(findings) == 0 {
+		return 0, false
+	}
+	max := findings[0].Severity
+	for i := range findings {
+		if findings[i].Severity > max {
+			max = findings[i].Severity
+		}
+	}
+	return max, true
+}
+
+type auditor struct {
+	cfg      *generator.Config
+	opts     Options
+	findings []Finding
+}
+
+func (a *auditor) report(id string, severity Severity, directive string, format string, args ...interface{}) {
+	a.findings = append(a.findings, Finding{
+		ID:        id,
+		Severity:  severity,
+		Directive: directive,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+func (a *auditor) isClient() bool {
+	return a.cfg.Has("client") || a.cfg.Has("tls-client")
+}
+
+func (a *auditor) checkCompression() {
+	isCompressed := func(name string, values []string) bool {
+		switch name {
+		case "comp-lzo":
+			return len(values) == 0 || values[0] != "no"
+		case "compress":
+			return len(values) > 0 && !strings.HasPrefix(values[0], "stub")
+		}
+		return false
+	}
+
+	for _, name := range []string{"comp-lzo", "compress"} {
+		if !a.cfg.Has(name) {
+			continue
+		}
+		values, _ := a.cfg.Get(name)
+		if isCompressed(name, values) {
+			a.report("compression", SeverityHigh, name, "compression is enabled, which exposes the tunnel to VORACLE attacks")
+		}
+	}
+
+	for _, push := range a.cfg.GetAll("push") {
+		if len(push) == 0 {
+			continue
+		}
+		fields := strings.Fields(push[0])
+		if len(fields) > 0 && isCompressed(fields[0], fields[1:]) {
+			a.report("compression", SeverityHigh, "push", "compression is pushed to clients (%q), which exposes the tunnel to VORACLE attacks", push[0])
+		}
+	}
+}
+
+func (a *auditor) checkControlChannel() {
+	if a.cfg.Has("tls-crypt") || a.cfg.Has("tls-crypt-v2") {
+		return
+	}
+	if a.cfg.Has("tls-auth") {
+		a.report("tls-crypt", SeverityLow, "tls-auth", "tls-auth authenticates but does not encrypt the control channel, consider tls-crypt")
+		return
+	}
+	a.report("tls-crypt", SeverityMedium, "tls-crypt", "the control channel is not protected by tls-crypt")
+}
+
+var weakCiphers = []string{"BF-", "DES-", "DES-EDE", "DESX-", "RC2-", "RC4", "CAST5-", "SEED-", "IDEA-"}
+
+func (a *auditor) checkCipher(directive string, name string) {
+	upper := strings.ToUpper(name)
+
+	if upper == "NONE" {
+		a.report("weak-cipher", SeverityCritical, directive, "data channel encryption is disabled")
+		return
+	}
+
+	for _, prefix := range weakCiphers {
+		if strings.HasPrefix(upper, prefix) {
+			a.report("weak-cipher", SeverityHigh, directive, "weak cipher %s", name)
+			return
+		}
+	}
+
+	if strings.HasSuffix(upper, "-CBC") {
+		a.report("weak-cipher", SeverityLow, directive, "cipher %s is not an AEAD cipher, prefer AES-GCM or CHACHA20-POLY1305", name)
+	}
+}
+
+func (a *auditor) checkCiphers() {
+	for _, name := range []string{"cipher", "data-ciphers-fallback"} {
+		if values, ok := a.cfg.Get(name); ok && len(values) > 0 {
+			a.checkCipher(name, values[0])
+		}
+	}
+
+	for _, name := range []string{"data-ciphers", "ncp-ciphers"} {
+		if values, ok := a.cfg.Get(name); ok && len(values) > 0 {
+			for _, cipher := range strings.Split(values[0], ":") {
+				a.checkCipher(name, cipher)
+			}
+		}
+	}
+
+	if values, ok := a.cfg.Get("auth"); ok && len(values) > 0 {
+		switch strings.ToUpper(values[0]) {
+		case "NONE":
+			a.report("weak-auth", SeverityCritical, "auth", "packet authentication is disabled")
+		case "MD5", "SHA1", "RSA-SHA1":
+			a.report("weak-auth", SeverityMedium, "auth", "weak HMAC digest %s", values[0])
+		}
+	}
+
+	if values, ok := a.cfg.Get("tls-version-min"); ok && len(values) > 0 {
+		if values[0] == "1.0" || values[0] == "1.1" {
+			a.report("tls-version", SeverityMedium, "tls-version-min", "TLS %s is deprecated, use 1.2 or later", values[0])
+		}
+	}
+}
+
+func (a *auditor) checkRemoteCert() {
+	if a.isClient() {
+		if values, ok := a.cfg.Get("remote-cert-tls"); ok && len(values) > 0 && values[0] == "server" {
+			return
+		}
+		if a.cfg.Has("verify-x509-name") || a.cfg.Has("remote-cert-eku") {
+			return
+		}
+		a.report("remote-cert-tls", SeverityHigh, "remote-cert-tls", "the server certificate usage is not verified, any client certificate signed by the CA could impersonate the server")
+		return
+	}
+
+	if a.cfg.Has("remote-cert-tls") || a.cfg.Has("remote-cert-eku") {
+		return
+	}
+	a.report("remote-cert-tls", SeverityMedium, "remote-cert-tls", "the client certificate usage is not verified")
+}
+
+func (a *auditor) checkCertificates() {
+	for _, name := range []string{"ca", "cert", "extra-certs"} {
+		buf, ok := a.cfg.Embedded(name)
+		if !ok {
+			continue
+		}
+
+		certs, err := certtool.ParseCertificatesPEM(buf)
+		if err != nil {
+			a.report("certificate", SeverityHigh, name, "could not parse certificate: %v", err)
+			continue
+		}
+
+		for _, cert := range certs {
+			a.checkCertificate(name, cert)
+		}
+	}
+}
+
+func (a *auditor) checkCertificate(directive string, cert *x509.Certificate) {
+	subject := cert.Subject.CommonName
+
+	if pub, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+		if bits := pub.N.BitLen(); bits < a.opts.MinRSABits {
+			a.report("rsa-key-size", SeverityHigh, directive, "%q uses a %d bit RSA key, at least %d bits are required", subject, bits, a.opts.MinRSABits)
+		}
+	}
+
+	switch cert.SignatureAlgorithm {
+	case x509.SHA1WithRSA, x509.ECDSAWithSHA1, x509.DSAWithSHA1:
+		a.report("sha1-signature", SeverityMedium, directive, "%q is signed with SHA-1", subject)
+	case x509.MD5WithRSA, x509.MD2WithRSA:
+		a.report("md5-signature", SeverityHigh, directive, "%q is signed with MD5", subject)
+	}
+
+	switch {
+	case a.opts.Now.After(cert.NotAfter):
+		a.report("certificate-expired", SeverityCritical, directive, "%q expired on %s", subject, cert.NotAfter.Format(time.RFC3339))
+	case a.opts.Now.Add(a.opts.ExpiryWindow).After(cert.NotAfter):
+		a.report("certificate-expiring", SeverityMedium, directive, "%q expires on %s", subject, cert.NotAfter.Format(time.RFC3339))
+	case a.opts.Now.Before(cert.NotBefore):
+		a.report("certificate-not-yet-valid", SeverityLow, directive, "%q is not valid before %s", subject, cert.NotBefore.Format(time.RFC3339))
+	}
+}
+
+func (a *auditor) checkDH() {
+	buf, ok := a.cfg.Embedded("dh")
+	if !ok {
+		return
+	}
+
+	block, _ := pem.Decode(buf)
+	if block == nil {
+		a.report("dh", SeverityMedium, "dh", "could not decode Diffie-Hellman parameters")
+		return
+	}
+
+	p, _, err := certtool.ParseDHParams(block.Bytes)
+	if err != nil {
+		a.report("dh", SeverityMedium, "dh", "could not parse Diffie-Hellman parameters: %v", err)
+		return
+	}
+
+	if bits := p.BitLen(); bits < a.opts.MinRSABits {
+		a.report("dh-size", SeverityHigh, "dh", "Diffie-Hellman parameters are %d bits long, at least %d bits are required", bits, a.opts.MinRSABits)
+	}
+}
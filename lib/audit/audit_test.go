@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+func findingIDs(findings []Finding) map[string]Severity {
+	ids := map[string]Severity{}
+	for _, finding := range findings {
+		ids[finding.ID] = finding.Severity
+	}
+	return ids
+}
+
+func selfSigned(t *testing.T, bits int, notAfter time.Time) []byte {
+	priv, err := rsa.GenerateKey(rand.Reader, bits)
+	assert.NoError(t, err)
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &priv.PublicKey, priv)
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestCheckWeakConfig(t *testing.T) {
+	config := generator.New()
+
+	config.MustEnable("client")
+	config.MustSet("cipher", "BF-CBC")
+	config.MustEnable("comp-lzo")
+	config.MustEmbed("ca", selfSigned(t, 1024, time.Now().Add(24*time.Hour)))
+
+	findings := Check(config, nil)
+	ids := findingIDs(findings)
+
+	assert.Equal(t, SeverityHigh, ids["compression"])
+	assert.Equal(t, SeverityHigh, ids["weak-cipher"])
+	assert.Equal(t, SeverityHigh, ids["remote-cert-tls"])
+	assert.Equal(t, SeverityMedium, ids["tls-crypt"])
+	assert.Equal(t, SeverityHigh, ids["rsa-key-size"])
+	assert.Equal(t, SeverityMedium, ids["certificate-expiring"])
+
+	max, ok := Max(findings)
+	assert.True(t, ok)
+	assert.Equal(t, SeverityHigh, max)
+	assert.Equal(t, SeverityHigh, findings[0].Severity)
+
+	buf, err := json.Marshal(findings[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(buf), `"severity":"high"`)
+}
+
+func TestCheckStrongConfig(t *testing.T) {
+	config := generator.New()
+
+	config.MustEnable("client")
+	config.MustSet("cipher", "AES-256-GCM")
+	config.MustSet("remote-cert-tls", "server")
+	config.MustEmbed("ca", selfSigned(t, 2048, time.Now().AddDate(1, 0, 0)))
+	config.MustEmbed("tls-crypt", []byte("static key"))
+
+	findings := Check(config, nil)
+	assert.Empty(t, findings)
+
+	_, ok := Max(findings)
+	assert.False(t, ok)
+}
+
+func TestParseSeverity(t *testing.T) {
+	severity, err := ParseSeverity("HIGH")
+	assert.NoError(t, err)
+	assert.Equal(t, SeverityHigh, severity)
+
+	_, err = ParseSeverity("extreme")
+	assert.Error(t, err)
+}
@@ -89,7 +89,9 @@ func buildCert(tpl *x509.Certificate, parent *x509.Certificate, parentKey crypto
 	return cert, der, nil
 }
 
-// BuildCA creates a self-signed CA certificate.
+// BuildCA creates a self-signed CA certificate. Its key usage allows signing
+// certificates and revocation lists, which crypto/x509 requires from the
+// issuer of a CRL.
 func BuildCA() (cert []byte, key []byte, err error) {
 	now := time.Now()
 
@@ -99,6 +101,7 @@ func BuildCA() (cert []byte, key []byte, err error) {
 		NotBefore:             now,
 		NotAfter:              now.AddDate(10, 0, 0),
 		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		MaxPathLenZero:        true,
 		BasicConstraintsValid: true,
 	}
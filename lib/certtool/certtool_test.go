@@ -1,9 +1,11 @@
 package certtool
 
 import (
+	"crypto/x509"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"software.sslmate.com/src/go-pkcs12"
 )
 
 func TestBuildCA(t *testing.T) {
@@ -12,6 +14,11 @@ func TestBuildCA(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, cert)
 	assert.NotNil(t, key)
+
+	ca, err := x509.ParseCertificate(cert)
+	assert.NoError(t, err)
+	assert.True(t, ca.IsCA)
+	assert.Equal(t, x509.KeyUsageCertSign|x509.KeyUsageCRLSign, ca.KeyUsage)
 }
 
 func TestBuildKeyServer(t *testing.T) {
@@ -33,3 +40,29 @@ func TestBuildKey(t *testing.T) {
 	assert.NotNil(t, cert)
 	assert.NotNil(t, key)
 }
+
+func TestBuildPKCS12(t *testing.T) {
+	caCert, caKey, err := BuildCA()
+	assert.NoError(t, err)
+
+	cert, key, err := BuildClientCertificate(caCert, caKey, "client.local")
+	assert.NoError(t, err)
+
+	_, err = BuildPKCS12(cert, key, [][]byte{caCert}, "", false)
+	assert.Error(t, err)
+
+	for _, modern := range []bool{false, true} {
+		buf, err := BuildPKCS12(cert, key, [][]byte{caCert}, "s3cr3t", modern)
+		assert.NoError(t, err)
+
+		privateKey, certificate, caCerts, err := pkcs12.DecodeChain(buf, "s3cr3t")
+		assert.NoError(t, err)
+		assert.NotNil(t, privateKey)
+		assert.Equal(t, cert, certificate.Raw)
+		assert.Equal(t, 1, len(caCerts))
+		assert.Equal(t, caCert, caCerts[0].Raw)
+
+		_, _, _, err = pkcs12.DecodeChain(buf, "wrong")
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,74 @@
+package certtool
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+)
+
+// ParseCertificatesPEM decodes every CERTIFICATE block found in buf.
+func ParseCertificatesPEM(buf []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+
+	for {
+		var block *pem.Block
+		block, buf = pem.Decode(buf)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+
+	return certs, nil
+}
+
+// ParsePrivateKey parses a DER encoded private key in PKCS#8, PKCS#1 or SEC 1
+// form.
+func ParsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		return signer, nil
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.New("could not parse private key")
+}
+
+// ParseDHParams parses DER encoded PKCS#3 Diffie-Hellman parameters.
+func ParseDHParams(der []byte) (p *big.Int, g *big.Int, err error) {
+	var params struct {
+		P *big.Int
+		G *big.Int
+	}
+
+	if _, err := asn1.Unmarshal(der, &params); err != nil {
+		return nil, nil, err
+	}
+
+	return params.P, params.G, nil
+}
@@ -0,0 +1,44 @@
+package certtool
+
+import (
+	"crypto/x509"
+	"errors"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// BuildPKCS12 bundles a DER encoded certificate, its private key and the CA
+// chain into a password protected PKCS#12 file. Unless modern is set, the
+// file is encrypted with 3DES and SHA-1, which every client can import; modern
+// files use AES-256 and PBKDF2, which older clients don't support.
+func BuildPKCS12(cert []byte, key []byte, caCerts [][]byte, password string, modern bool) ([]byte, error) {
+	if password == "" {
+		return nil, errors.New("a password is required")
+	}
+
+	certificate, err := x509.ParseCertificate(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := ParsePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]*x509.Certificate, 0, len(caCerts))
+	for _, der := range caCerts {
+		ca, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, ca)
+	}
+
+	encoder := pkcs12.LegacyDES
+	if modern {
+		encoder = pkcs12.Modern
+	}
+
+	return encoder.Encode(privateKey, certificate, chain, password)
+}
@@ -0,0 +1,82 @@
+// Package clientconnect builds the configuration OpenVPN applies to a client
+// when it connects, written by the client-connect script, from the static
+// addresses and pushed options of a firewall.Policy. Keeping them in the
+// policy replaces one client-config-dir file per client.
+package clientconnect
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/xiam/openvpn-config-generator/lib/firewall"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+// Env holds the variables OpenVPN runs client-connect scripts with.
+type Env struct {
+	// CommonName of the client certificate, or the username with
+	// username-as-common-name.
+	CommonName string
+
+	// Netmask of the server network, set with topology subnet.
+	Netmask string
+
+	// IPv6Local is the address of the server in the IPv6 network and
+	// IPv6Netbits the length of its prefix.
+	IPv6Local   string
+	IPv6Netbits string
+}
+
+// EnvFromGetenv reads the variables with getenv, like os.Getenv.
+func EnvFromGetenv(getenv func(string) string) Env {
+	return Env{
+		CommonName:  getenv("common_name"),
+		Netmask:     getenv("ifconfig_netmask"),
+		IPv6Local:   getenv("ifconfig_ipv6_local"),
+		IPv6Netbits: getenv("ifconfig_ipv6_netbits"),
+	}
+}
+
+// Config returns the configuration of the client env.CommonName: its static
+// addresses, with ifconfig-push and ifconfig-ipv6-push, and the options
+// pushed to it, to every group it belongs to and to every client, in the
+// order of the policy.
+func Config(policy *firewall.Policy, env Env) (*generator.Config, error) {
+	if env.CommonName == "" {
+		return nil, errors.New("missing common name")
+	}
+
+	config := generator.New()
+
+	for _, ip := range policy.Addresses[env.CommonName] {
+		if ip.To4() != nil {
+			if net.ParseIP(env.Netmask) == nil {
+				return nil, errors.New("static IPv4 addresses need topology subnet on the server")
+			}
+			if err := config.Add("ifconfig-push", ip.String(), env.Netmask); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if env.IPv6Local == "" || env.IPv6Netbits == "" {
+			return nil, errors.New("static IPv6 addresses need server-ipv6 on the server")
+		}
+		address := fmt.Sprintf("%s/%s", ip, env.IPv6Netbits)
+		if err := config.Add("ifconfig-ipv6-push", address, env.IPv6Local); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, push := range policy.Pushes {
+		if !policy.Matches(push.Source, env.CommonName) {
+			continue
+		}
+		if err := config.Add("push", push.Option); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,64 @@
+package clientconnect
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xiam/openvpn-config-generator/lib/firewall"
+)
+
+const policy = `
+group admins alice bob
+
+address alice 10.9.0.10 fd00:9::10
+address carol 10.9.0.12
+
+push @admins route 192.168.1.0 255.255.255.0
+push * dhcp-option DNS 192.168.1.53
+push carol redirect-gateway def1
+`
+
+var env = map[string]string{
+	"common_name":           "alice",
+	"ifconfig_netmask":      "255.255.0.0",
+	"ifconfig_ipv6_local":   "fd00:9::1",
+	"ifconfig_ipv6_netbits": "64",
+}
+
+func TestConfig(t *testing.T) {
+	p, err := firewall.ParsePolicy(strings.NewReader(policy))
+	assert.NoError(t, err)
+
+	e := EnvFromGetenv(func(name string) string { return env[name] })
+	assert.Equal(t, "alice", e.CommonName)
+
+	config, err := Config(p, e)
+	assert.NoError(t, err)
+
+	buf, err := config.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, `ifconfig-push "10.9.0.10" "255.255.0.0"
+ifconfig-ipv6-push "fd00:9::10/64" "fd00:9::1"
+push "route 192.168.1.0 255.255.255.0"
+push "dhcp-option DNS 192.168.1.53"`, string(buf))
+
+	// Clients without a static address get one from the pool.
+	e.CommonName = "dave"
+	config, err = Config(p, e)
+	assert.NoError(t, err)
+
+	buf, err = config.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, `push "dhcp-option DNS 192.168.1.53"`, string(buf))
+
+	// Without topology subnet.
+	e.CommonName = "carol"
+	e.Netmask = ""
+	_, err = Config(p, e)
+	assert.Error(t, err)
+
+	e.CommonName = ""
+	_, err = Config(p, e)
+	assert.Error(t, err)
+}
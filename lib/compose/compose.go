@@ -0,0 +1,98 @@
+// Package compose lays out a docker-compose deployment of an OpenVPN server
+// that runs on a stock image, without a Dockerfile: an entrypoint script
+// installs OpenVPN and sets up NAT when the container starts.
+//
+// The bundle looks like:
+//
+//	docker-compose.yml
+//	.env             PORT, PROTO, NETWORK and NETWORK_MASK, see container.Env
+//	entrypoint.sh
+//	openvpn/         the configuration, mounted on /etc/openvpn
+//	openvpn/ccd/
+//	pki/             certificates and keys, mounted read-only
+package compose
+
+import (
+	"bytes"
+	"errors"
+	"text/template"
+
+	"github.com/xiam/openvpn-config-generator/lib/container"
+)
+
+// Files of the bundle.
+const (
+	ComposeFile    = "docker-compose.yml"
+	EnvFile        = ".env"
+	EntrypointFile = "entrypoint.sh"
+	ConfigDir      = "openvpn"
+	PKIDir         = "pki"
+)
+
+// Options of the bundle.
+type Options struct {
+	// Service is the name of the docker-compose service.
+	Service string
+
+	// Image the server runs on, it must be Alpine based.
+	Image string
+
+	// Config is the name of the configuration file in ConfigDir.
+	Config string
+}
+
+var composeTemplate = template.Must(template.New("compose").Parse(`services:
+  {{.Service}}:
+    image: {{.Image}}
+    restart: unless-stopped
+    entrypoint: ["/bin/sh", "/entrypoint.sh"]
+    command: ["{{.Config}}"]
+    cap_add:
+      - NET_ADMIN
+    devices:
+      - /dev/net/tun
+    sysctls:
+      - net.ipv4.ip_forward=1
+    ports:
+      - "${PORT}:${PORT}/${PROTO}"
+    environment:
+      PORT: ${PORT}
+      PROTO: ${PROTO}
+      NETWORK: ${NETWORK}
+      NETWORK_MASK: ${NETWORK_MASK}
+    volumes:
+      - ./{{.EntrypointFile}}:/entrypoint.sh:ro
+      - ./{{.ConfigDir}}:/etc/openvpn
+      - ./{{.PKIDir}}:/etc/openvpn/{{.PKIDir}}:ro
+`))
+
+// Compose returns the docker-compose.yml file.
+func Compose(opts Options) ([]byte, error) {
+	if opts.Service == "" || opts.Config == "" {
+		return nil, errors.New("missing service or configuration name")
+	}
+	if opts.Image == "" {
+		opts.Image = container.DefaultImage
+	}
+
+	var buf bytes.Buffer
+	err := composeTemplate.Execute(&buf, map[string]string{
+		"Service":        opts.Service,
+		"Image":          opts.Image,
+		"Config":         opts.Config,
+		"EntrypointFile": EntrypointFile,
+		"ConfigDir":      ConfigDir,
+		"PKIDir":         PKIDir,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Entrypoint returns the entrypoint script, OpenVPN runs from the
+// directory the configuration is mounted on.
+func Entrypoint() []byte {
+	return container.Entrypoint("/etc/openvpn")
+}
@@ -0,0 +1,25 @@
+package compose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xiam/openvpn-config-generator/lib/container"
+)
+
+func TestCompose(t *testing.T) {
+	buf, err := Compose(Options{Service: "openvpn", Config: "server.conf"})
+	assert.NoError(t, err)
+
+	compose := string(buf)
+	assert.Contains(t, compose, "  openvpn:\n    image: "+container.DefaultImage+"\n")
+	assert.Contains(t, compose, `command: ["server.conf"]`)
+	assert.Contains(t, compose, `- "${PORT}:${PORT}/${PROTO}"`)
+	assert.Contains(t, compose, "NETWORK: ${NETWORK}\n")
+	assert.Contains(t, compose, "- ./pki:/etc/openvpn/pki:ro\n")
+
+	_, err = Compose(Options{Service: "openvpn"})
+	assert.Error(t, err)
+
+	assert.Contains(t, string(Entrypoint()), `exec openvpn --cd /etc/openvpn --config "$1"`)
+}
@@ -0,0 +1,110 @@
+// Package container holds what the compose and k8s deployments share: the
+// variables that describe the server and the entrypoint script that runs it
+// on a stock Alpine image.
+package container
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	ovpncfg "github.com/xiam/openvpn-config-generator"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+// DefaultImage is the image the server runs on.
+const DefaultImage = "alpine:3.20"
+
+// Env holds the variables the entrypoint reads, they follow the names of
+// the variables NewServerConfig reads.
+type Env struct {
+	Port        int
+	Proto       string
+	Network     string
+	NetworkMask string
+}
+
+// EnvFromConfig returns the variables matching a server configuration.
+func EnvFromConfig(config *generator.Config) (*Env, error) {
+	env := &Env{Port: 1194, Proto: "udp"}
+
+	if values, ok := config.Get("port"); ok && len(values) > 0 {
+		port, err := strconv.Atoi(values[0])
+		if err != nil {
+			return nil, fmt.Errorf("port: invalid port %q", values[0])
+		}
+		env.Port = port
+	}
+
+	if values, ok := config.Get("proto"); ok && len(values) > 0 {
+		if strings.HasPrefix(values[0], "tcp") {
+			env.Proto = "tcp"
+		}
+	}
+
+	networks, err := ovpncfg.ServerNetworks(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, network := range networks {
+		if network.IP.To4() != nil {
+			env.Network = network.IP.String()
+			env.NetworkMask = net.IP(network.Mask).String()
+			break
+		}
+	}
+	if env.Network == "" {
+		return nil, errors.New("an IPv4 server network is required")
+	}
+
+	return env, nil
+}
+
+// File returns the variables in the format of docker-compose's .env file.
+func (env *Env) File() []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "PORT=%d\n", env.Port)
+	fmt.Fprintf(&buf, "PROTO=%s\n", env.Proto)
+	fmt.Fprintf(&buf, "NETWORK=%s\n", env.Network)
+	fmt.Fprintf(&buf, "NETWORK_MASK=%s\n", env.NetworkMask)
+
+	return buf.Bytes()
+}
+
+const entrypoint = `#!/bin/sh
+# Runs OpenVPN on a stock Alpine image with the configuration file given as
+# the first argument. PORT, PROTO, NETWORK and NETWORK_MASK come from the
+# environment.
+set -e
+
+if ! command -v openvpn >/dev/null; then
+	apk add --no-cache openvpn iptables >/dev/null
+fi
+
+if [ ! -c /dev/net/tun ]; then
+	mkdir -p /dev/net
+	mknod /dev/net/tun c 10 200
+fi
+
+if [ "$(cat /proc/sys/net/ipv4/ip_forward)" != 1 ]; then
+	sysctl -w net.ipv4.ip_forward=1 >/dev/null || echo "warning: could not enable IP forwarding" >&2
+fi
+
+iptables -t nat -C POSTROUTING -s "$NETWORK/$NETWORK_MASK" -j MASQUERADE 2>/dev/null ||
+	iptables -t nat -A POSTROUTING -s "$NETWORK/$NETWORK_MASK" -j MASQUERADE
+
+mkdir -p %[1]s/ccd
+
+exec openvpn --cd %[1]s --config "$1" --port "$PORT" --proto "$PROTO"
+`
+
+// Entrypoint returns the entrypoint script. OpenVPN runs from workdir,
+// where it keeps the client-config-dir, so the path of the configuration
+// file is relative to it.
+func Entrypoint(workdir string) []byte {
+	return []byte(fmt.Sprintf(entrypoint, workdir))
+}
@@ -0,0 +1,33 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ovpncfg "github.com/xiam/openvpn-config-generator"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+func TestEnvFromConfig(t *testing.T) {
+	config, err := ovpncfg.NewServerConfig()
+	assert.NoError(t, err)
+
+	config.MustSet("port", 443)
+	config.MustSet("proto", "tcp-server")
+
+	env, err := EnvFromConfig(config)
+	assert.NoError(t, err)
+
+	assert.Equal(t, &Env{Port: 443, Proto: "tcp", Network: "10.9.0.0", NetworkMask: "255.255.0.0"}, env)
+	assert.Equal(t, "PORT=443\nPROTO=tcp\nNETWORK=10.9.0.0\nNETWORK_MASK=255.255.0.0\n", string(env.File()))
+
+	_, err = EnvFromConfig(generator.New())
+	assert.Error(t, err)
+}
+
+func TestEntrypoint(t *testing.T) {
+	script := string(Entrypoint("/var/lib/openvpn"))
+
+	assert.Contains(t, script, "mkdir -p /var/lib/openvpn/ccd\n")
+	assert.Contains(t, script, `exec openvpn --cd /var/lib/openvpn --config "$1" --port "$PORT" --proto "$PROTO"`)
+}
@@ -0,0 +1,125 @@
+// Package deploy lays out the files that run an OpenVPN server with the
+// openvpn-server@.service systemd unit shipped by OpenVPN: the
+// configuration, a sysctl drop-in that enables forwarding and the NAT rules.
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+)
+
+// Paths of the layout.
+const (
+	ConfigDir   = "/etc/openvpn/server"
+	UnitDir     = "/etc/systemd/system"
+	SysctlFile  = "/etc/sysctl.d/30-openvpn-forward.conf"
+	NFTablesDir = "/etc/nftables.d"
+	IPTablesDir = "/etc/iptables"
+	TmpfilesDir = "/etc/tmpfiles.d"
+)
+
+// TOTPStateFile is where auth-verify keeps the last one-time code of every
+// user. OpenVPN writes it after dropping privileges, so its directory is
+// created by a tmpfiles.d drop-in.
+const TOTPStateFile = "/var/lib/ovpn-cfgen/totp-state"
+
+// UnitName is the name of the systemd template unit.
+const UnitName = "openvpn-server@.service"
+
+// unit mirrors the unit shipped by OpenVPN, for distributions that don't
+// package it.
+const unit = `[Unit]
+Description=OpenVPN service for %I
+After=network-online.target
+Wants=network-online.target
+Documentation=man:openvpn(8)
+
+[Service]
+Type=notify
+PrivateTmp=true
+WorkingDirectory=/etc/openvpn/server
+ExecStart=/usr/sbin/openvpn --status %t/openvpn-server/status-%i.log --status-version 2 --suppress-timestamps --config %i.conf
+RuntimeDirectory=openvpn-server
+RuntimeDirectoryMode=0710
+CapabilityBoundingSet=CAP_IPC_LOCK CAP_NET_ADMIN CAP_NET_BIND_SERVICE CAP_NET_RAW CAP_SETGID CAP_SETUID CAP_SYS_CHROOT CAP_DAC_OVERRIDE CAP_AUDIT_WRITE
+LimitNPROC=10
+DeviceAllow=/dev/null rw
+DeviceAllow=/dev/net/tun rw
+ProtectSystem=true
+ProtectHome=true
+KillMode=process
+RestartSec=5s
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// ConfigFile returns the path of the configuration of server name, the
+// unit reads it from its working directory.
+func ConfigFile(name string) string {
+	return path.Join(ConfigDir, name+".conf")
+}
+
+// ServiceName returns the name of the service instance of server name.
+func ServiceName(name string) string {
+	return "openvpn-server@" + name + ".service"
+}
+
+// NFTablesFile returns the path of the nftables rules of server name.
+func NFTablesFile(name string) string {
+	return path.Join(NFTablesDir, "openvpn-"+name+".nft")
+}
+
+// IPTablesFile returns the path of the iptables rules of server name.
+func IPTablesFile(name string) string {
+	return path.Join(IPTablesDir, "openvpn-"+name+".rules.v4")
+}
+
+// IP6TablesFile returns the path of the ip6tables rules of server name.
+func IP6TablesFile(name string) string {
+	return path.Join(IPTablesDir, "openvpn-"+name+".rules.v6")
+}
+
+// TmpfilesFile returns the path of the tmpfiles.d drop-in of server name.
+func TmpfilesFile(name string) string {
+	return path.Join(TmpfilesDir, "openvpn-"+name+".conf")
+}
+
+// Tmpfiles returns a tmpfiles.d drop-in that creates dir, owned by the user
+// and group OpenVPN runs as. Empty ones leave it to root.
+func Tmpfiles(dir string, user string, group string) []byte {
+	if user == "" {
+		user = "-"
+	}
+	if group == "" {
+		group = "-"
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString("# State OpenVPN scripts write after dropping privileges.\n")
+	fmt.Fprintf(&buf, "d %s 0700 %s %s -\n", dir, user, group)
+
+	return buf.Bytes()
+}
+
+// Unit returns the openvpn-server@.service template unit.
+func Unit() []byte {
+	return []byte(unit)
+}
+
+// Sysctl returns a sysctl drop-in that enables IPv4 forwarding, and IPv6
+// forwarding if ipv6 is true.
+func Sysctl(ipv6 bool) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("# Route the traffic of OpenVPN clients.\n")
+	buf.WriteString("net.ipv4.ip_forward = 1\n")
+	if ipv6 {
+		buf.WriteString("net.ipv6.conf.all.forwarding = 1\n")
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,30 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayout(t *testing.T) {
+	assert.Equal(t, "/etc/openvpn/server/office.conf", ConfigFile("office"))
+	assert.Equal(t, "openvpn-server@office.service", ServiceName("office"))
+	assert.Equal(t, "/etc/nftables.d/openvpn-office.nft", NFTablesFile("office"))
+	assert.Equal(t, "/etc/iptables/openvpn-office.rules.v4", IPTablesFile("office"))
+	assert.Equal(t, "/etc/iptables/openvpn-office.rules.v6", IP6TablesFile("office"))
+	assert.Equal(t, "/etc/tmpfiles.d/openvpn-office.conf", TmpfilesFile("office"))
+
+	assert.Contains(t, string(Unit()), "WorkingDirectory="+ConfigDir+"\n")
+	assert.Contains(t, string(Unit()), "--config %i.conf")
+}
+
+func TestSysctl(t *testing.T) {
+	assert.Contains(t, string(Sysctl(false)), "net.ipv4.ip_forward = 1\n")
+	assert.NotContains(t, string(Sysctl(false)), "ipv6")
+	assert.Contains(t, string(Sysctl(true)), "net.ipv6.conf.all.forwarding = 1\n")
+}
+
+func TestTmpfiles(t *testing.T) {
+	assert.Contains(t, string(Tmpfiles("/var/lib/ovpn-cfgen", "nobody", "nogroup")), "\nd /var/lib/ovpn-cfgen 0700 nobody nogroup -\n")
+	assert.Contains(t, string(Tmpfiles("/var/lib/ovpn-cfgen", "", "")), "\nd /var/lib/ovpn-cfgen 0700 - - -\n")
+}
@@ -0,0 +1,245 @@
+// Package expiry finds certificates that are about to expire.
+package expiry
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xiam/openvpn-config-generator/lib/certtool"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+	"github.com/xiam/openvpn-config-generator/lib/pki"
+)
+
+// Status of a certificate relative to the reporting window.
+type Status string
+
+const (
+	StatusValid    Status = "valid"
+	StatusExpiring Status = "expiring"
+	StatusExpired  Status = "expired"
+)
+
+// Certificate is a certificate found while scanning.
+type Certificate struct {
+	Path       string    `json:"path"`
+	CommonName string    `json:"common_name"`
+	Serial     string    `json:"serial"`
+	Role       string    `json:"role,omitempty"`
+	NotAfter   time.Time `json:"not_after"`
+	Status     Status    `json:"status"`
+	DaysLeft   int       `json:"days_left"`
+}
+
+// Report is the result of checking a set of certificates against a window.
+type Report struct {
+	Now          time.Time     `json:"now"`
+	Window       time.Duration `json:"window"`
+	Certificates []Certificate `json:"certificates"`
+}
+
+// Count returns how many certificates have the given status.
+func (r *Report) Count(status Status) int {
+	n := 0
+	for i := range r.Certificates {
+		if r.Certificates[i].Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Filter returns the certificates that are expiring or already expired.
+func (r *Report) Filter() []Certificate {
+	res := []Certificate{}
+	for i := range r.Certificates {
+		if r.Certificates[i].Status != StatusValid {
+			res = append(res, r.Certificates[i])
+		}
+	}
+	return res
+}
+
+// Check classifies certs according to how close to expiration they are and
+// sorts them by expiry date.
+func Check(certs []Certificate, now time.Time, window time.Duration) *Report {
+	report := &Report{
+		Now:          now,
+		Window:       window,
+		Certificates: make([]Certificate, 0, len(certs)),
+	}
+
+	for _, cert := range certs {
+		cert.DaysLeft = int(cert.NotAfter.Sub(now).Hours() / 24)
+		switch {
+		case now.After(cert.NotAfter):
+			cert.Status = StatusExpired
+		case now.Add(window).After(cert.NotAfter):
+			cert.Status = StatusExpiring
+		default:
+			cert.Status = StatusValid
+		}
+		report.Certificates = append(report.Certificates, cert)
+	}
+
+	sort.SliceStable(report.Certificates, func(i, j int) bool {
+		return report.Certificates[i].NotAfter.Before(report.Certificates[j].NotAfter)
+	})
+
+	return report
+}
+
+func newCertificate(path string, cert *x509.Certificate) Certificate {
+	return Certificate{
+		Path:       path,
+		CommonName: cert.Subject.CommonName,
+		Serial:     strings.ToUpper(cert.SerialNumber.Text(16)),
+		Role:       certificateRole(cert),
+		NotAfter:   cert.NotAfter,
+	}
+}
+
+// certificateRole tells whether cert belongs to a CA, a server or a client.
+func certificateRole(cert *x509.Certificate) string {
+	if cert.IsCA {
+		return "ca"
+	}
+	for _, eku := range cert.ExtKeyUsage {
+		switch eku {
+		case x509.ExtKeyUsageServerAuth:
+			return "server"
+		case x509.ExtKeyUsageClientAuth:
+			return "client"
+		}
+	}
+	return ""
+}
+
+var scanExtensions = map[string]bool{
+	".crt":  true,
+	".pem":  true,
+	".cert": true,
+	".ovpn": true,
+	".conf": true,
+}
+
+// ScanDir looks for certificates in PEM files and inside the inline blocks of
+// configuration files under dir. A certificate found in several files is only
+// reported once.
+func ScanDir(dir string) ([]Certificate, error) {
+	certs := []Certificate{}
+	seen := map[[sha256.Size]byte]bool{}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !scanExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		for _, cert := range findCertificates(buf) {
+			sum := sha256.Sum256(cert.Raw)
+			if seen[sum] {
+				continue
+			}
+			seen[sum] = true
+			certs = append(certs, newCertificate(path, cert))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return certs, nil
+}
+
+func findCertificates(buf []byte) []*x509.Certificate {
+	if certs, err := certtool.ParseCertificatesPEM(buf); err == nil {
+		return certs
+	}
+
+	cfg, err := generator.Parse(buf)
+	if err != nil {
+		return nil
+	}
+
+	res := []*x509.Certificate{}
+	for _, name := range []string{"ca", "cert", "extra-certs"} {
+		if embedded, ok := cfg.Embedded(name); ok {
+			if certs, err := certtool.ParseCertificatesPEM(embedded); err == nil {
+				res = append(res, certs...)
+			}
+		}
+	}
+
+	return res
+}
+
+// ParseIndex reads an OpenSSL/easy-rsa style index.txt database and returns
+// the certificates that were not revoked.
+func ParseIndex(r io.Reader) ([]Certificate, error) {
+	entries, err := pki.ReadIndex(r)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := []Certificate{}
+	for _, entry := range entries {
+		if entry.Status == pki.StatusRevoked {
+			continue
+		}
+		certs = append(certs, Certificate{
+			Path:       entry.File,
+			CommonName: entry.CommonName,
+			Serial:     entry.Serial,
+			NotAfter:   entry.NotAfter,
+		})
+	}
+
+	return certs, nil
+}
+
+// WritePrometheus writes report in the Prometheus text exposition format, as
+// expected by the node exporter's textfile collector.
+func WritePrometheus(w io.Writer, report *Report) error {
+	var b strings.Builder
+
+	b.WriteString("# HELP openvpn_certificate_expiry_timestamp_seconds Time at which the certificate expires.\n")
+	b.WriteString("# TYPE openvpn_certificate_expiry_timestamp_seconds gauge\n")
+	for _, cert := range report.Certificates {
+		fmt.Fprintf(&b, "openvpn_certificate_expiry_timestamp_seconds{common_name=\"%s\",serial=\"%s\",path=\"%s\"} %d\n",
+			labelValue(cert.CommonName), labelValue(cert.Serial), labelValue(cert.Path), cert.NotAfter.Unix())
+	}
+
+	b.WriteString("# HELP openvpn_certificates_expiring Number of certificates expiring within the reporting window.\n")
+	b.WriteString("# TYPE openvpn_certificates_expiring gauge\n")
+	fmt.Fprintf(&b, "openvpn_certificates_expiring %d\n", report.Count(StatusExpiring))
+
+	b.WriteString("# HELP openvpn_certificates_expired Number of expired certificates.\n")
+	b.WriteString("# TYPE openvpn_certificates_expired gauge\n")
+	fmt.Fprintf(&b, "openvpn_certificates_expired %d\n", report.Count(StatusExpired))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelValue(s string) string {
+	return labelReplacer.Replace(s)
+}
@@ -0,0 +1,77 @@
+package expiry
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xiam/openvpn-config-generator/lib/certtool"
+)
+
+func TestScanDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "expiry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	caCert, caKey, err := certtool.BuildCA()
+	assert.NoError(t, err)
+
+	clientCert, _, err := certtool.BuildClientCertificate(caCert, caKey, "client.local")
+	assert.NoError(t, err)
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert})
+	clientPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCert})
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ca.crt"), caPEM, 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "client.ovpn"), []byte("client\n<ca>\n"+string(caPEM)+"</ca>\n<cert>\n"+string(clientPEM)+"</cert>\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), caPEM, 0644))
+
+	certs, err := ScanDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(certs))
+	assert.Equal(t, "ca", certs[0].Role)
+	assert.Equal(t, "client", certs[1].Role)
+
+	report := Check(certs, time.Now(), 30*24*time.Hour)
+	assert.Equal(t, 2, report.Count(StatusValid))
+	assert.Empty(t, report.Filter())
+
+	report = Check(certs, time.Now().AddDate(10, 0, -10), 30*24*time.Hour)
+	assert.Equal(t, 2, report.Count(StatusExpiring))
+
+	report = Check(certs, time.Now().AddDate(11, 0, 0), 30*24*time.Hour)
+	assert.Equal(t, 2, report.Count(StatusExpired))
+}
+
+func TestParseIndex(t *testing.T) {
+	index := strings.Join([]string{
+		"V\t300101000000Z\t\t01\tunknown\t/O=ACME/CN=server",
+		"R\t300101000000Z\t200101000000Z\t02\tunknown\t/O=ACME/CN=old",
+		"V\t20200101000000Z\t\t0A\tunknown\t/CN=laptop",
+	}, "\n")
+
+	certs, err := ParseIndex(strings.NewReader(index))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(certs))
+	assert.Equal(t, "server", certs[0].CommonName)
+	assert.Equal(t, 2030, certs[0].NotAfter.Year())
+	assert.Equal(t, "laptop", certs[1].CommonName)
+
+	report := Check(certs, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), 24*time.Hour)
+	assert.Equal(t, 1, report.Count(StatusExpired))
+	assert.Equal(t, "laptop", report.Certificates[0].CommonName)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WritePrometheus(&buf, report))
+	assert.Contains(t, buf.String(), `openvpn_certificate_expiry_timestamp_seconds{common_name="server",serial="01",path="unknown"} 1893456000`)
+	assert.Contains(t, buf.String(), "openvpn_certificates_expired 1\n")
+
+	_, err = ParseIndex(strings.NewReader("V\tbroken"))
+	assert.Error(t, err)
+}
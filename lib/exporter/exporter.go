@@ -0,0 +1,161 @@
+// Package exporter serves Prometheus metrics about an OpenVPN server.
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xiam/openvpn-config-generator/lib/expiry"
+	"github.com/xiam/openvpn-config-generator/lib/management"
+	"github.com/xiam/openvpn-config-generator/lib/status"
+)
+
+// Source provides the current status of the server.
+type Source interface {
+	Status() (*status.Status, error)
+}
+
+// StatusFile reads the status from a file written by the status directive.
+type StatusFile string
+
+// Status implements Source.
+func (f StatusFile) Status() (*status.Status, error) {
+	fp, err := os.Open(string(f))
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	return status.Parse(fp)
+}
+
+// Management asks the management interface for the status on every scrape.
+type Management struct {
+	Network string
+	Address string
+	Options *management.Options
+}
+
+// Status implements Source.
+func (m *Management) Status() (*status.Status, error) {
+	c, err := management.Dial(m.Network, m.Address, m.Options)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	buf, err := c.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	return status.Parse(bytes.NewReader(buf))
+}
+
+// Exporter is an http.Handler that writes metrics in the Prometheus text
+// exposition format.
+type Exporter struct {
+	Source Source
+
+	// Workdir, if not empty, is scanned for certificates to report their
+	// expiration dates.
+	Workdir string
+
+	// ExpiryWindow is used to count expiring certificates.
+	ExpiryWindow time.Duration
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labels(pairs ...string) string {
+	parts := []string{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, pairs[i], labelReplacer.Replace(pairs[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func header(w io.Writer, name string, kind string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// WriteMetrics writes the current metrics to w.
+func (e *Exporter) WriteMetrics(w io.Writer) error {
+	var b bytes.Buffer
+
+	st, err := e.Source.Status()
+	if err != nil {
+		log.Printf("failed to read status: %v", err)
+	}
+
+	header(&b, "openvpn_up", "gauge", "Whether the status of the server could be read.")
+	if err != nil {
+		fmt.Fprintf(&b, "openvpn_up 0\n")
+	} else {
+		fmt.Fprintf(&b, "openvpn_up 1\n")
+		writeStatus(&b, st)
+	}
+
+	if e.Workdir != "" {
+		certs, err := expiry.ScanDir(e.Workdir)
+		if err != nil {
+			return err
+		}
+		report := expiry.Check(certs, time.Now(), e.ExpiryWindow)
+		if err := expiry.WritePrometheus(&b, report); err != nil {
+			return err
+		}
+	}
+
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+func writeStatus(w io.Writer, st *status.Status) {
+	header(w, "openvpn_status_update_time_seconds", "gauge", "Time at which the status was last updated by the server.")
+	fmt.Fprintf(w, "openvpn_status_update_time_seconds %d\n", st.Updated.Unix())
+
+	header(w, "openvpn_server_connected_clients", "gauge", "Number of connected clients.")
+	fmt.Fprintf(w, "openvpn_server_connected_clients %d\n", len(st.Clients))
+
+	clientLabels := func(client *status.Client) string {
+		return labels(
+			"common_name", client.CommonName,
+			"real_address", client.RealAddress,
+			"virtual_address", client.VirtualAddress,
+		)
+	}
+
+	header(w, "openvpn_server_client_received_bytes_total", "counter", "Bytes received from the client.")
+	for i := range st.Clients {
+		fmt.Fprintf(w, "openvpn_server_client_received_bytes_total%s %d\n", clientLabels(&st.Clients[i]), st.Clients[i].BytesReceived)
+	}
+
+	header(w, "openvpn_server_client_sent_bytes_total", "counter", "Bytes sent to the client.")
+	for i := range st.Clients {
+		fmt.Fprintf(w, "openvpn_server_client_sent_bytes_total%s %d\n", clientLabels(&st.Clients[i]), st.Clients[i].BytesSent)
+	}
+
+	header(w, "openvpn_server_client_connected_since_seconds", "gauge", "Time at which the client connected.")
+	for i := range st.Clients {
+		fmt.Fprintf(w, "openvpn_server_client_connected_since_seconds%s %d\n", clientLabels(&st.Clients[i]), st.Clients[i].ConnectedSince.Unix())
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var b bytes.Buffer
+	if err := e.WriteMetrics(&b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(b.Bytes())
+}
@@ -0,0 +1,116 @@
+package exporter
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xiam/openvpn-config-generator/lib/management"
+	"github.com/xiam/openvpn-config-generator/lib/status"
+)
+
+const statusV2 = `TITLE,OpenVPN 2.5.1 x86_64-pc-linux-gnu
+TIME,2021-01-01 00:10:00,1609459800
+HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Virtual IPv6 Address,Bytes Received,Bytes Sent,Connected Since,Connected Since (time_t),Username,Client ID,Peer ID
+CLIENT_LIST,laptop,192.0.2.1:49502,10.9.0.2,,334948,1973012,2021-01-01 00:00:00,1609459200,UNDEF,0,0
+END
+`
+
+type brokenSource struct{}
+
+func (brokenSource) Status() (*status.Status, error) {
+	return nil, errors.New("no status")
+}
+
+func TestExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exporter")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	statusFile := filepath.Join(dir, "status.log")
+	assert.NoError(t, ioutil.WriteFile(statusFile, []byte(statusV2), 0644))
+
+	e := &Exporter{Source: StatusFile(statusFile), Workdir: dir}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "openvpn_up 1\n")
+	assert.Contains(t, body, "openvpn_server_connected_clients 1\n")
+	assert.Contains(t, body, `openvpn_server_client_received_bytes_total{common_name="laptop",real_address="192.0.2.1:49502",virtual_address="10.9.0.2"} 334948`)
+	assert.Contains(t, body, `openvpn_server_client_sent_bytes_total{common_name="laptop",real_address="192.0.2.1:49502",virtual_address="10.9.0.2"} 1973012`)
+	assert.Contains(t, body, "openvpn_certificates_expired 0\n")
+}
+
+func TestExporterDown(t *testing.T) {
+	e := &Exporter{Source: brokenSource{}}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Contains(t, rec.Body.String(), "openvpn_up 0\n")
+	assert.NotContains(t, rec.Body.String(), "openvpn_server_connected_clients")
+}
+
+// serveManagement answers "status 2" on one connection the way the OpenVPN
+// management interface does.
+func serveManagement(listener net.Listener) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	io.WriteString(conn, ">INFO:OpenVPN Management Interface Version 5 -- type 'help' for more info\r\n")
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch strings.TrimSpace(line) {
+		case "status 2":
+			io.WriteString(conn, strings.Replace(statusV2, "\n", "\r\n", -1))
+		case "quit":
+			return
+		}
+	}
+}
+
+func TestExporterManagement(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	go serveManagement(listener)
+
+	e := &Exporter{Source: &Management{
+		Network: "tcp",
+		Address: listener.Addr().String(),
+		Options: &management.Options{Timeout: 2 * time.Second},
+	}}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, 200, rec.Code)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "openvpn_up 1\n")
+	assert.Contains(t, body, "openvpn_server_connected_clients 1\n")
+}
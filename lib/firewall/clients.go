@@ -0,0 +1,68 @@
+package firewall
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+// Client is a client with static addresses, assigned by ifconfig-push and
+// ifconfig-ipv6-push in its client-config-dir file.
+type Client struct {
+	Name      string
+	Addresses []net.IP
+}
+
+// ReadClients reads the clients with static addresses from a
+// client-config-dir, where every file is named after the common name of a
+// client. A missing directory holds no clients.
+func ReadClients(dir string) ([]Client, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	clients := []Client{}
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+
+		buf, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		config, err := generator.Parse(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		client := Client{Name: entry.Name()}
+
+		if values, ok := config.Get("ifconfig-push"); ok && len(values) > 0 {
+			if ip := net.ParseIP(values[0]); ip != nil {
+				client.Addresses = append(client.Addresses, ip)
+			}
+		}
+
+		if values, ok := config.Get("ifconfig-ipv6-push"); ok && len(values) > 0 {
+			if ip := net.ParseIP(strings.SplitN(values[0], "/", 2)[0]); ip != nil {
+				client.Addresses = append(client.Addresses, ip)
+			}
+		}
+
+		if len(client.Addresses) > 0 {
+			clients = append(clients, client)
+		}
+	}
+
+	return clients, nil
+}
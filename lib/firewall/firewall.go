@@ -0,0 +1,364 @@
+// Package firewall generates the firewall rules of an OpenVPN server from
+// its configuration, in nftables and iptables-restore formats: they accept
+// connections on the listening port, forward and masquerade the traffic of
+// clients and, given a Policy, restrict what each client can reach.
+package firewall
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	ovpncfg "github.com/xiam/openvpn-config-generator"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+const (
+	defaultPort  = 1194
+	defaultProto = "udp"
+)
+
+// Ruleset holds the firewall rules of a server.
+type Ruleset struct {
+	// Name identifies the rules, like the name of the server.
+	Name string
+
+	// Port and Proto (udp or tcp) the server listens on.
+	Port  int
+	Proto string
+
+	// Device is the tun or tap device of the server, without a number it
+	// matches every device of that kind.
+	Device string
+
+	// Networks clients get their addresses from.
+	Networks []*net.IPNet
+
+	// Interface traffic leaves through, any interface but the VPN if
+	// empty.
+	Interface string
+
+	// Clients with static addresses, needed by Policy for the clients it
+	// has no address statement for.
+	Clients []Client
+
+	// Policy restricts what clients can reach, they can reach anything if
+	// nil.
+	Policy *Policy
+}
+
+// FromConfig returns the rules for a server configuration.
+func FromConfig(name string, config *generator.Config) (*Ruleset, error) {
+	networks, err := ovpncfg.ServerNetworks(config)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Ruleset{
+		Name:     name,
+		Port:     defaultPort,
+		Proto:    defaultProto,
+		Device:   "tun",
+		Networks: networks,
+	}
+
+	for _, directive := range []string{"port", "lport"} {
+		if values, ok := config.Get(directive); ok && len(values) > 0 {
+			if r.Port, err = strconv.Atoi(values[0]); err != nil {
+				return nil, fmt.Errorf("%s: invalid port %q", directive, values[0])
+			}
+		}
+	}
+
+	if values, ok := config.Get("proto"); ok && len(values) > 0 {
+		if strings.HasPrefix(values[0], "tcp") {
+			r.Proto = "tcp"
+		}
+	}
+
+	if values, ok := config.Get("dev"); ok && len(values) > 0 {
+		r.Device = values[0]
+	}
+
+	return r, nil
+}
+
+// HasIPv6 reports whether any of the networks is an IPv6 network.
+func (r *Ruleset) HasIPv6() bool {
+	for _, network := range r.Networks {
+		if isIPv6(network) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIPv6(network *net.IPNet) bool {
+	return network.IP.To4() == nil
+}
+
+func (r *Ruleset) table() string {
+	return "openvpn-" + r.Name
+}
+
+// device returns the device name with wildcard, the one used by nft or
+// iptables, appended to names without a number.
+func (r *Ruleset) device(wildcard string) string {
+	if r.Device == strings.TrimRight(r.Device, "0123456789") {
+		return r.Device + wildcard
+	}
+	return r.Device
+}
+
+// access is a Rule expanded for one address family.
+type access struct {
+	sources     []*net.IPNet
+	destination *net.IPNet
+	proto       string
+	port        int
+}
+
+// sources returns the addresses of the clients a rule applies to.
+func (r *Ruleset) sources(source string) ([]*net.IPNet, error) {
+	if source == AllClients {
+		return r.Networks, nil
+	}
+
+	names := []string{source}
+	if strings.HasPrefix(source, "@") {
+		names = r.Policy.Groups[source[1:]]
+	}
+
+	sources := []*net.IPNet{}
+	for _, name := range names {
+		addresses, ok := r.addresses(name)
+		if !ok {
+			return nil, fmt.Errorf("client %q has no static address in the policy or the client-config-dir", name)
+		}
+		for _, ip := range addresses {
+			sources = append(sources, hostNetwork(ip))
+		}
+	}
+
+	return sources, nil
+}
+
+// addresses returns the static addresses of a client, from the policy or
+// else from Clients.
+func (r *Ruleset) addresses(name string) ([]net.IP, bool) {
+	if addresses, ok := r.Policy.Addresses[name]; ok {
+		return addresses, true
+	}
+	for _, client := range r.Clients {
+		if client.Name == name {
+			return client.Addresses, true
+		}
+	}
+	return nil, false
+}
+
+// access expands the rules of the policy for IPv4 or IPv6.
+func (r *Ruleset) access(ipv6 bool) ([]access, error) {
+	if r.Policy == nil {
+		return nil, nil
+	}
+
+	res := []access{}
+	for i, rule := range r.Policy.Rules {
+		sources, err := r.sources(rule.Source)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+
+		if rule.Destination != nil && isIPv6(rule.Destination) != ipv6 {
+			continue
+		}
+
+		a := access{destination: rule.Destination, proto: rule.Proto, port: rule.Port}
+		for _, source := range sources {
+			if isIPv6(source) == ipv6 {
+				a.sources = append(a.sources, source)
+			}
+		}
+		if len(a.sources) > 0 {
+			res = append(res, a)
+		}
+	}
+
+	return res, nil
+}
+
+// verdict returns what happens to the traffic of clients no rule allows.
+func (r *Ruleset) verdict() string {
+	if r.Policy != nil && len(r.Policy.Rules) > 0 {
+		return "drop"
+	}
+	return "accept"
+}
+
+// NFTables returns an nftables script, to be loaded with nft -f, that adds
+// a table with the rules. The table is deleted first so the script can be
+// loaded again.
+//
+// An accept verdict only ends the chain it is in: packets still go through
+// the base chains of other tables on the same hook, and are dropped if one
+// of them drops them, e.g. a filter table with policy drop.
+func (r *Ruleset) NFTables() ([]byte, error) {
+	var buf bytes.Buffer
+
+	table := r.table()
+	device := fmt.Sprintf("iifname %q", r.device("*"))
+
+	fmt.Fprintf(&buf, "table inet %s\n", table)
+	fmt.Fprintf(&buf, "delete table inet %s\n\n", table)
+
+	fmt.Fprintf(&buf, "table inet %s {\n", table)
+
+	buf.WriteString("\tchain input {\n")
+	buf.WriteString("\t\ttype filter hook input priority filter; policy accept;\n")
+	fmt.Fprintf(&buf, "\t\t%s dport %d accept\n", r.Proto, r.Port)
+	buf.WriteString("\t}\n\n")
+
+	buf.WriteString("\tchain forward {\n")
+	buf.WriteString("\t\ttype filter hook forward priority filter; policy accept;\n")
+	buf.WriteString("\t\tct state established,related accept\n")
+	for _, ipv6 := range []bool{false, true} {
+		rules, err := r.access(ipv6)
+		if err != nil {
+			return nil, err
+		}
+		family := "ip"
+		if ipv6 {
+			family = "ip6"
+		}
+		for _, a := range rules {
+			fmt.Fprintf(&buf, "\t\t%s %s saddr %s", device, family, nftSet(a.sources))
+			if a.destination != nil {
+				fmt.Fprintf(&buf, " %s daddr %s", family, a.destination)
+			}
+			switch {
+			case a.port > 0:
+				fmt.Fprintf(&buf, " %s dport %d", a.proto, a.port)
+			case a.proto == "icmp" && ipv6:
+				buf.WriteString(" meta l4proto ipv6-icmp")
+			case a.proto != "":
+				fmt.Fprintf(&buf, " meta l4proto %s", a.proto)
+			}
+			buf.WriteString(" accept\n")
+		}
+	}
+	fmt.Fprintf(&buf, "\t\t%s %s\n", device, r.verdict())
+	buf.WriteString("\t}\n\n")
+
+	buf.WriteString("\tchain postrouting {\n")
+	buf.WriteString("\t\ttype nat hook postrouting priority srcnat; policy accept;\n")
+	for _, network := range r.Networks {
+		family := "ip"
+		if isIPv6(network) {
+			family = "ip6"
+		}
+		fmt.Fprintf(&buf, "\t\t%s saddr %s %s daddr != %s", family, network, family, network)
+		if r.Interface != "" {
+			fmt.Fprintf(&buf, " oifname %q", r.Interface)
+		}
+		buf.WriteString(" masquerade\n")
+	}
+	buf.WriteString("\t}\n")
+
+	buf.WriteString("}\n")
+
+	return buf.Bytes(), nil
+}
+
+func nftSet(networks []*net.IPNet) string {
+	items := make([]string, 0, len(networks))
+	for _, network := range networks {
+		items = append(items, network.String())
+	}
+	if len(items) == 1 {
+		return items[0]
+	}
+	return "{ " + strings.Join(items, ", ") + " }"
+}
+
+// IPTables returns the IPv4 rules in the iptables-restore format, to be
+// loaded with iptables-restore --noflush.
+//
+// The rules live in chains of their own, which are flushed every time they
+// are loaded, but iptables-restore can't tell whether the jumps to them from
+// INPUT, FORWARD and POSTROUTING exist: loading the rules again adds another
+// set of jumps. Load them once, e.g. at boot, or delete the jumps first.
+func (r *Ruleset) IPTables() ([]byte, error) {
+	return r.iptables(false)
+}
+
+// IP6Tables returns the IPv6 rules in the ip6tables-restore format, to be
+// loaded with ip6tables-restore --noflush.
+func (r *Ruleset) IP6Tables() ([]byte, error) {
+	return r.iptables(true)
+}
+
+func (r *Ruleset) iptables(ipv6 bool) ([]byte, error) {
+	rules, err := r.access(ipv6)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	input := r.table() + "-input"
+	forward := r.table() + "-forward"
+	postrouting := r.table() + "-postrouting"
+	device := r.device("+")
+
+	// Declaring the chains flushes them, the jumps to them are added again
+	// on every load.
+	buf.WriteString("*filter\n")
+	fmt.Fprintf(&buf, ":%s - [0:0]\n", input)
+	fmt.Fprintf(&buf, ":%s - [0:0]\n", forward)
+	fmt.Fprintf(&buf, "-I INPUT -j %s\n", input)
+	fmt.Fprintf(&buf, "-I FORWARD -j %s\n", forward)
+	fmt.Fprintf(&buf, "-A %s -p %s --dport %d -j ACCEPT\n", input, r.Proto, r.Port)
+	fmt.Fprintf(&buf, "-A %s -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT\n", forward)
+	for _, a := range rules {
+		sources := make([]string, 0, len(a.sources))
+		for _, source := range a.sources {
+			sources = append(sources, source.String())
+		}
+		fmt.Fprintf(&buf, "-A %s -i %s -s %s", forward, device, strings.Join(sources, ","))
+		if a.destination != nil {
+			fmt.Fprintf(&buf, " -d %s", a.destination)
+		}
+		switch {
+		case a.proto == "icmp" && ipv6:
+			buf.WriteString(" -p ipv6-icmp")
+		case a.proto != "":
+			fmt.Fprintf(&buf, " -p %s", a.proto)
+		}
+		if a.port > 0 {
+			fmt.Fprintf(&buf, " --dport %d", a.port)
+		}
+		buf.WriteString(" -j ACCEPT\n")
+	}
+	fmt.Fprintf(&buf, "-A %s -i %s -j %s\n", forward, device, strings.ToUpper(r.verdict()))
+	buf.WriteString("COMMIT\n")
+
+	buf.WriteString("*nat\n")
+	fmt.Fprintf(&buf, ":%s - [0:0]\n", postrouting)
+	fmt.Fprintf(&buf, "-I POSTROUTING -j %s\n", postrouting)
+	for _, network := range r.Networks {
+		if isIPv6(network) != ipv6 {
+			continue
+		}
+		fmt.Fprintf(&buf, "-A %s -s %s ! -d %s", postrouting, network, network)
+		if r.Interface != "" {
+			fmt.Fprintf(&buf, " -o %s", r.Interface)
+		}
+		buf.WriteString(" -j MASQUERADE\n")
+	}
+	buf.WriteString("COMMIT\n")
+
+	return buf.Bytes(), nil
+}
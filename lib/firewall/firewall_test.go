@@ -0,0 +1,212 @@
+package firewall
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ovpncfg "github.com/xiam/openvpn-config-generator"
+)
+
+const policy = `
+# Administrators reach the LAN over SSH.
+group admins alice bob
+
+allow @admins 192.168.1.0/24 tcp/22
+allow alice any
+allow * 192.168.1.53 udp/53
+allow bob fd00:1::/64 icmp
+`
+
+func ruleset(t *testing.T) *Ruleset {
+	config, err := ovpncfg.NewServerConfig()
+	assert.NoError(t, err)
+
+	config.MustSet("server-ipv6", "fd00:9::/64")
+
+	r, err := FromConfig("office", config)
+	assert.NoError(t, err)
+
+	return r
+}
+
+func TestFromConfig(t *testing.T) {
+	r := ruleset(t)
+
+	assert.Equal(t, "office", r.Name)
+	assert.Equal(t, 1194, r.Port)
+	assert.Equal(t, "udp", r.Proto)
+	assert.Equal(t, "tun", r.Device)
+	assert.Equal(t, 2, len(r.Networks))
+	assert.True(t, r.HasIPv6())
+}
+
+func TestNFTables(t *testing.T) {
+	r := ruleset(t)
+	r.Interface = "eth0"
+
+	buf, err := r.NFTables()
+	assert.NoError(t, err)
+
+	assert.Equal(t, `table inet openvpn-office
+delete table inet openvpn-office
+
+table inet openvpn-office {
+	chain input {
+		type filter hook input priority filter; policy accept;
+		udp dport 1194 accept
+	}
+
+	chain forward {
+		type filter hook forward priority filter; policy accept;
+		ct state established,related accept
+		iifname "tun*" accept
+	}
+
+	chain postrouting {
+		type nat hook postrouting priority srcnat; policy accept;
+		ip saddr 10.9.0.0/16 ip daddr != 10.9.0.0/16 oifname "eth0" masquerade
+		ip6 saddr fd00:9::/64 ip6 daddr != fd00:9::/64 oifname "eth0" masquerade
+	}
+}
+`, string(buf))
+}
+
+func TestPolicy(t *testing.T) {
+	p, err := ParsePolicy(strings.NewReader(policy))
+	assert.NoError(t, err)
+
+	r := ruleset(t)
+	r.Device = "tun0"
+	r.Policy = p
+	r.Clients = []Client{
+		{Name: "alice", Addresses: []net.IP{net.ParseIP("10.9.0.10")}},
+		{Name: "bob", Addresses: []net.IP{net.ParseIP("10.9.0.11"), net.ParseIP("fd00:9::11")}},
+	}
+
+	buf, err := r.NFTables()
+	assert.NoError(t, err)
+
+	assert.Contains(t, string(buf), `
+		ct state established,related accept
+		iifname "tun0" ip saddr { 10.9.0.10/32, 10.9.0.11/32 } ip daddr 192.168.1.0/24 tcp dport 22 accept
+		iifname "tun0" ip saddr 10.9.0.10/32 accept
+		iifname "tun0" ip saddr 10.9.0.0/16 ip daddr 192.168.1.53/32 udp dport 53 accept
+		iifname "tun0" ip6 saddr fd00:9::11/128 ip6 daddr fd00:1::/64 meta l4proto ipv6-icmp accept
+		iifname "tun0" drop
+`)
+
+	buf, err = r.IPTables()
+	assert.NoError(t, err)
+
+	assert.Equal(t, `*filter
+:openvpn-office-input - [0:0]
+:openvpn-office-forward - [0:0]
+-I INPUT -j openvpn-office-input
+-I FORWARD -j openvpn-office-forward
+-A openvpn-office-input -p udp --dport 1194 -j ACCEPT
+-A openvpn-office-forward -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT
+-A openvpn-office-forward -i tun0 -s 10.9.0.10/32,10.9.0.11/32 -d 192.168.1.0/24 -p tcp --dport 22 -j ACCEPT
+-A openvpn-office-forward -i tun0 -s 10.9.0.10/32 -j ACCEPT
+-A openvpn-office-forward -i tun0 -s 10.9.0.0/16 -d 192.168.1.53/32 -p udp --dport 53 -j ACCEPT
+-A openvpn-office-forward -i tun0 -j DROP
+COMMIT
+*nat
+:openvpn-office-postrouting - [0:0]
+-I POSTROUTING -j openvpn-office-postrouting
+-A openvpn-office-postrouting -s 10.9.0.0/16 ! -d 10.9.0.0/16 -j MASQUERADE
+COMMIT
+`, string(buf))
+
+	buf, err = r.IP6Tables()
+	assert.NoError(t, err)
+	assert.Contains(t, string(buf), "-A openvpn-office-forward -i tun0 -s fd00:9::11/128 -d fd00:1::/64 -p ipv6-icmp -j ACCEPT\n")
+	assert.Contains(t, string(buf), "-A openvpn-office-postrouting -s fd00:9::/64 ! -d fd00:9::/64 -j MASQUERADE\n")
+
+	// Rules need the static address of every client.
+	r.Clients = r.Clients[:1]
+	_, err = r.NFTables()
+	assert.Error(t, err)
+}
+
+func TestPolicyAddresses(t *testing.T) {
+	p, err := ParsePolicy(strings.NewReader(policy + `
+address alice 10.9.0.10
+push @admins route 192.168.1.0 255.255.255.0
+push * dhcp-option DNS 192.168.1.53
+`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "10.9.0.10", p.Addresses["alice"][0].String())
+	assert.Equal(t, []Push{
+		{Source: "@admins", Option: "route 192.168.1.0 255.255.255.0"},
+		{Source: "*", Option: "dhcp-option DNS 192.168.1.53"},
+	}, p.Pushes)
+
+	assert.True(t, p.Matches("@admins", "bob"))
+	assert.True(t, p.Matches("*", "carol"))
+	assert.True(t, p.Matches("alice", "alice"))
+	assert.False(t, p.Matches("@admins", "carol"))
+	assert.False(t, p.Matches("alice", "bob"))
+
+	// Addresses of the policy come before the ones of client-config-dir.
+	r := ruleset(t)
+	r.Policy = p
+	r.Clients = []Client{
+		{Name: "alice", Addresses: []net.IP{net.ParseIP("10.9.0.99")}},
+		{Name: "bob", Addresses: []net.IP{net.ParseIP("10.9.0.11")}},
+	}
+
+	buf, err := r.NFTables()
+	assert.NoError(t, err)
+	assert.Contains(t, string(buf), "ip saddr 10.9.0.10/32 accept\n")
+	assert.NotContains(t, string(buf), "10.9.0.99")
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	for _, s := range []string{
+		"deny alice any",
+		"allow alice",
+		"allow alice 10.0.0.300",
+		"allow alice any sctp",
+		"allow alice any tcp/0",
+		"allow alice any icmp/8",
+		"allow @nobody any",
+		"group admins",
+		"address alice",
+		"address alice 10.9.0",
+		"address @admins 10.9.0.10",
+		"push alice",
+		"push @nobody route 10.0.0.0 255.0.0.0",
+	} {
+		_, err := ParsePolicy(strings.NewReader(s))
+		assert.Error(t, err, s)
+	}
+}
+
+func TestReadClients(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccd")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "alice"), []byte("ifconfig-push 10.9.0.10 255.255.0.0\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bob"), []byte("ifconfig-push 10.9.0.11 255.255.0.0\nifconfig-ipv6-push fd00:9::11/64\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "carol"), []byte("push \"route 192.168.2.0 255.255.255.0\"\n"), 0644))
+
+	clients, err := ReadClients(dir)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, len(clients))
+	assert.Equal(t, "alice", clients[0].Name)
+	assert.Equal(t, "10.9.0.10", clients[0].Addresses[0].String())
+	assert.Equal(t, "bob", clients[1].Name)
+	assert.Equal(t, "fd00:9::11", clients[1].Addresses[1].String())
+
+	clients, err = ReadClients(filepath.Join(dir, "missing"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(clients))
+}
@@ -0,0 +1,216 @@
+package firewall
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// AllClients is the source of rules that apply to every client.
+const AllClients = "*"
+
+// Rule allows a client, a group of clients (prefixed by "@") or every
+// client (AllClients) to reach Destination.
+type Rule struct {
+	Source string
+
+	// Destination network, nil for any destination.
+	Destination *net.IPNet
+
+	// Proto is tcp, udp or icmp, empty for any protocol.
+	Proto string
+
+	// Port is the destination port for tcp and udp, 0 for any port.
+	Port int
+}
+
+// Push sends an option to a client, a group of clients or every client when
+// they connect.
+type Push struct {
+	Source string
+	Option string
+}
+
+// Policy restricts what clients can reach through the VPN and holds the
+// static addresses and options clients get when they connect.
+//
+// A policy is read from a text file with one statement per line:
+//
+//	# group NAME CLIENT...
+//	group admins alice bob
+//
+//	# allow SOURCE DESTINATION [PROTO[/PORT]]
+//	allow @admins 192.168.1.0/24 tcp/22
+//	allow alice any
+//	allow * 192.168.1.53 udp/53
+//
+//	# address CLIENT ADDRESS...
+//	address alice 10.9.0.10 fd00:9::10
+//
+//	# push SOURCE OPTION
+//	push @admins route 192.168.1.0 255.255.255.0
+type Policy struct {
+	Groups    map[string][]string
+	Rules     []Rule
+	Addresses map[string][]net.IP
+	Pushes    []Push
+}
+
+// ParsePolicy reads a policy.
+func ParsePolicy(r io.Reader) (*Policy, error) {
+	policy := &Policy{
+		Groups:    map[string][]string{},
+		Addresses: map[string][]net.IP{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "group":
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("line %d: expecting group NAME CLIENT...", lineNo)
+			}
+			policy.Groups[fields[1]] = append(policy.Groups[fields[1]], fields[2:]...)
+		case "allow":
+			if len(fields) < 3 || len(fields) > 4 {
+				return nil, fmt.Errorf("line %d: expecting allow SOURCE DESTINATION [PROTO[/PORT]]", lineNo)
+			}
+			rule, err := parseRule(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			policy.Rules = append(policy.Rules, *rule)
+		case "address":
+			if len(fields) < 3 || strings.HasPrefix(fields[1], "@") || fields[1] == AllClients {
+				return nil, fmt.Errorf("line %d: expecting address CLIENT ADDRESS...", lineNo)
+			}
+			for _, field := range fields[2:] {
+				ip := net.ParseIP(field)
+				if ip == nil {
+					return nil, fmt.Errorf("line %d: invalid address %q", lineNo, field)
+				}
+				policy.Addresses[fields[1]] = append(policy.Addresses[fields[1]], ip)
+			}
+		case "push":
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("line %d: expecting push SOURCE OPTION", lineNo)
+			}
+			policy.Pushes = append(policy.Pushes, Push{
+				Source: fields[1],
+				Option: strings.Join(fields[2:], " "),
+			})
+		default:
+			return nil, fmt.Errorf("line %d: unknown statement %q", lineNo, fields[0])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sources := []string{}
+	for _, rule := range policy.Rules {
+		sources = append(sources, rule.Source)
+	}
+	for _, push := range policy.Pushes {
+		sources = append(sources, push.Source)
+	}
+	for _, source := range sources {
+		if strings.HasPrefix(source, "@") {
+			if _, ok := policy.Groups[source[1:]]; !ok {
+				return nil, fmt.Errorf("unknown group %q", source[1:])
+			}
+		}
+	}
+
+	return policy, nil
+}
+
+// Matches reports whether source, a client, a group or AllClients, includes
+// client.
+func (p *Policy) Matches(source string, client string) bool {
+	switch {
+	case source == AllClients:
+		return true
+	case strings.HasPrefix(source, "@"):
+		for _, member := range p.Groups[source[1:]] {
+			if member == client {
+				return true
+			}
+		}
+		return false
+	}
+	return source == client
+}
+
+func parseRule(fields []string) (*Rule, error) {
+	rule := &Rule{Source: fields[0]}
+
+	if fields[1] != "any" {
+		destination, err := parseNetwork(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		rule.Destination = destination
+	}
+
+	if len(fields) < 3 {
+		return rule, nil
+	}
+
+	parts := strings.SplitN(fields[2], "/", 2)
+	switch parts[0] {
+	case "tcp", "udp":
+	case "icmp":
+		if len(parts) > 1 {
+			return nil, fmt.Errorf("icmp doesn't take a port")
+		}
+	default:
+		return nil, fmt.Errorf("unknown protocol %q", parts[0])
+	}
+	rule.Proto = parts[0]
+
+	if len(parts) > 1 {
+		port, err := strconv.Atoi(parts[1])
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %q", parts[1])
+		}
+		rule.Port = port
+	}
+
+	return rule, nil
+}
+
+// parseNetwork parses a network in CIDR notation or a single address.
+func parseNetwork(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		return network, err
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+	return hostNetwork(ip), nil
+}
+
+func hostNetwork(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"unicode"
 )
 
 type configType uint
@@ -106,14 +107,31 @@ func (cfg *Config) Enable(name string) error {
 	}, true)
 }
 
-func (cfg *Config) Add(name string, value ...interface{}) error {
+// stringValues formats values, rejecting the ones a configuration file can't
+// hold: OpenVPN would read what follows a line break as another directive.
+func stringValues(name string, value []interface{}) ([]string, error) {
 	if len(value) == 0 {
-		return errors.New("at least one value is required")
+		return nil, errors.New("at least one value is required")
 	}
 
 	strValues := make([]string, 0, len(value))
 	for i := range value {
-		strValues = append(strValues, fmt.Sprintf("%v", value[i]))
+		str := fmt.Sprintf("%v", value[i])
+		for _, r := range str {
+			if unicode.IsControl(r) && r != '\t' {
+				return nil, fmt.Errorf("%s: value %q holds a control character", name, str)
+			}
+		}
+		strValues = append(strValues, str)
+	}
+
+	return strValues, nil
+}
+
+func (cfg *Config) Add(name string, value ...interface{}) error {
+	strValues, err := stringValues(name, value)
+	if err != nil {
+		return err
 	}
 
 	return cfg.pushValue(&configValue{
@@ -124,6 +142,9 @@ func (cfg *Config) Add(name string, value ...interface{}) error {
 }
 
 func (cfg *Config) Set(name string, value ...interface{}) error {
+	if _, err := stringValues(name, value); err != nil {
+		return err
+	}
 	_ = cfg.Remove(name)
 	return cfg.Add(name, value...)
 }
@@ -137,3 +158,116 @@ func panicIfErr(err error) {
 		panic(fmt.Errorf("unexpected internal error: %v", err.Error()))
 	}
 }
+
+// Has reports whether the named directive is present.
+func (cfg *Config) Has(name string) bool {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	_, ok := cfg.keys[name]
+	return ok
+}
+
+// Get returns the values of the first directive with the given name.
+func (cfg *Config) Get(name string) ([]string, bool) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	for i := range cfg.values {
+		if cfg.values[i].Name == name {
+			return append([]string(nil), cfg.values[i].String...), true
+		}
+	}
+
+	return nil, false
+}
+
+// GetAll returns the values of every directive with the given name, in the
+// order they were added.
+func (cfg *Config) GetAll(name string) [][]string {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	res := [][]string{}
+	for i := range cfg.values {
+		if cfg.values[i].Name == name {
+			res = append(res, append([]string(nil), cfg.values[i].String...))
+		}
+	}
+
+	return res
+}
+
+// Embedded returns the contents of an inline <name> block.
+func (cfg *Config) Embedded(name string) ([]byte, bool) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	for i := range cfg.values {
+		if cfg.values[i].Name == name && cfg.values[i].Type == configTypeEmbed {
+			return append([]byte(nil), cfg.values[i].Embed...), true
+		}
+	}
+
+	return nil, false
+}
+
+// Names returns the name of every directive in the order they were added.
+func (cfg *Config) Names() []string {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	names := make([]string, 0, len(cfg.values))
+	for i := range cfg.values {
+		names = append(names, cfg.values[i].Name)
+	}
+
+	return names
+}
+
+// Clone returns a copy of the configuration.
+func (cfg *Config) Clone() *Config {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	clone := New()
+	for _, value := range cfg.values {
+		value.String = append([]string(nil), value.String...)
+		value.Embed = append([]byte(nil), value.Embed...)
+		clone.values = append(clone.values, value)
+		clone.keys[value.Name] = struct{}{}
+	}
+
+	return clone
+}
+
+// Unembed replaces the inline <name> block with a regular directive that
+// takes the given values, keeping its position. It returns the contents of
+// the block.
+func (cfg *Config) Unembed(name string, value ...interface{}) ([]byte, error) {
+	if len(value) == 0 {
+		return nil, errors.New("at least one value is required")
+	}
+
+	strValues := make([]string, 0, len(value))
+	for i := range value {
+		strValues = append(strValues, fmt.Sprintf("%v", value[i]))
+	}
+
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	for i := range cfg.values {
+		if cfg.values[i].Name == name && cfg.values[i].Type == configTypeEmbed {
+			embed := cfg.values[i].Embed
+			cfg.values[i] = configValue{
+				Name:   name,
+				Type:   configTypeString,
+				String: strValues,
+			}
+			return embed, nil
+		}
+	}
+
+	return nil, errors.New("key is not embedded")
+}
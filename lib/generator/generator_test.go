@@ -118,3 +118,153 @@ e6adb40fca5c5463
 
 	assert.Equal(t, "<key>\n"+string(value)+"\n</key>\nremote \"server2.mydomain\"\nremote \"server3.mydomain\"", string(buf))
 }
+
+func TestParse(t *testing.T) {
+	config := New()
+
+	config.MustEnable("client")
+	config.MustSet("remote", "vpn.example.org", 1194)
+	config.MustAdd("push", "dhcp-option DNS 8.8.8.8")
+	config.MustAdd("push", "ping 15")
+	config.MustEmbed("tls-crypt", []byte("foo\nbar\nbaz"))
+
+	buf, err := config.Compile()
+	assert.NoError(t, err)
+
+	parsed, err := Parse(buf)
+	assert.NoError(t, err)
+
+	assert.True(t, parsed.Has("client"))
+
+	remote, ok := parsed.Get("remote")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"vpn.example.org", "1194"}, remote)
+
+	assert.Equal(t, [][]string{{"dhcp-option DNS 8.8.8.8"}, {"ping 15"}}, parsed.GetAll("push"))
+
+	embed, ok := parsed.Embedded("tls-crypt")
+	assert.True(t, ok)
+	assert.Equal(t, "foo\nbar\nbaz", string(embed))
+
+	reparsed, err := parsed.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, string(buf), string(reparsed))
+}
+
+func TestParseComments(t *testing.T) {
+	parsed, err := Parse([]byte("# comment\n; another\n\nproto udp # trailing\nverb 'three words'\n"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"proto", "verb"}, parsed.Names())
+
+	verb, _ := parsed.Get("verb")
+	assert.Equal(t, []string{"three words"}, verb)
+
+	_, err = Parse([]byte("<ca>\nfoo\n"))
+	assert.Error(t, err)
+}
+
+func TestParseQuoting(t *testing.T) {
+	parsed, err := Parse([]byte(`cd "C:\Program Files\OpenVPN\config"
+up "C:\\scripts\\up.bat \"with args\""
+status C:\status\ file.log
+`))
+	assert.NoError(t, err)
+
+	cd, _ := parsed.Get("cd")
+	assert.Equal(t, []string{`C:\Program Files\OpenVPN\config`}, cd)
+
+	up, _ := parsed.Get("up")
+	assert.Equal(t, []string{`C:\scripts\up.bat "with args"`}, up)
+
+	status, _ := parsed.Get("status")
+	assert.Equal(t, []string{`C:\status file.log`}, status)
+
+	buf, err := parsed.Compile()
+	assert.NoError(t, err)
+	assert.Contains(t, string(buf), `cd "C:\\Program Files\\OpenVPN\\config"`)
+
+	reparsed, err := Parse(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, parsed.GetAll("up"), reparsed.GetAll("up"))
+	assert.Equal(t, parsed.GetAll("cd"), reparsed.GetAll("cd"))
+
+	_, err = Parse([]byte(`crl-verify "unterminated`))
+	assert.Error(t, err)
+}
+
+func TestControlCharacters(t *testing.T) {
+	config := New()
+	config.MustSet("static-challenge", "Code", 1)
+
+	assert.Error(t, config.Set("static-challenge", "Code\nup /tmp/x.sh", 1))
+	assert.Error(t, config.Add("push", "route 10.0.0.0\r255.0.0.0"))
+	assert.Error(t, config.Add("setenv", "NAME", "a\x00b"))
+
+	// A rejected Set leaves the previous value.
+	value, _ := config.Get("static-challenge")
+	assert.Equal(t, []string{"Code", "1"}, value)
+
+	buf, err := config.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, `static-challenge "Code" "1"`, string(buf))
+
+	parsed, err := Parse(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, config.GetAll("static-challenge"), parsed.GetAll("static-challenge"))
+}
+
+func TestUnembed(t *testing.T) {
+	config := New()
+
+	config.MustEnable("client")
+	config.MustEmbed("ca", []byte("CA"))
+	config.MustSet("verb", 3)
+
+	clone := config.Clone()
+
+	buf, err := clone.Unembed("ca", "ca.crt")
+	assert.NoError(t, err)
+	assert.Equal(t, "CA", string(buf))
+
+	_, err = clone.Unembed("ca", "ca.crt")
+	assert.Error(t, err)
+
+	_, err = clone.Unembed("verb", "3")
+	assert.Error(t, err)
+
+	compiled, err := clone.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, "client\nca \"ca.crt\"\nverb \"3\"", string(compiled))
+
+	// The original is left untouched.
+	_, ok := config.Embedded("ca")
+	assert.True(t, ok)
+}
+
+func TestSplit(t *testing.T) {
+	config := New()
+
+	config.MustEnable("client")
+	config.MustEmbed("ca", []byte("CA"))
+	config.MustEmbed("key", []byte("KEY"))
+	config.MustEmbed("pkcs12", []byte("UDEy\nMTI="))
+
+	split, files, err := config.Split(func(directive string) string {
+		return directive + ".pem"
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []File{
+		{Directive: "ca", Name: "ca.pem", Data: []byte("CA\n")},
+		{Directive: "key", Name: "key.pem", Data: []byte("KEY\n")},
+		{Directive: "pkcs12", Name: "pkcs12.pem", Data: []byte("P1212")},
+	}, files)
+
+	compiled, err := split.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, "client\nca \"ca.pem\"\nkey \"key.pem\"\npkcs12 \"pkcs12.pem\"", string(compiled))
+
+	_, ok := config.Embedded("key")
+	assert.True(t, ok)
+}
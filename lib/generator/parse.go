@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Parse reads an OpenVPN configuration file (like the ones produced by
+// Compile) and returns a Config holding its directives and inline blocks.
+func Parse(buf []byte) (*Config, error) {
+	cfg := New()
+
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var (
+		embedName string
+		embedBuf  []string
+		lineNo    int
+	)
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+
+		if embedName != "" {
+			if line == "</"+embedName+">" {
+				value := []byte(strings.Join(embedBuf, "\n"))
+				if err := cfg.pushValue(&configValue{
+					Name:  embedName,
+					Type:  configTypeEmbed,
+					Embed: bytes.TrimSpace(value),
+				}, false); err != nil {
+					return nil, err
+				}
+				embedName, embedBuf = "", nil
+				continue
+			}
+			embedBuf = append(embedBuf, line)
+			continue
+		}
+
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '<' {
+			if !strings.HasSuffix(line, ">") || strings.HasPrefix(line, "</") {
+				return nil, fmt.Errorf("line %d: unexpected %q", lineNo, line)
+			}
+			embedName = line[1 : len(line)-1]
+			continue
+		}
+
+		fields, err := splitFields(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		value := &configValue{Name: fields[0]}
+		if len(fields) > 1 {
+			value.Type = configTypeString
+			value.String = fields[1:]
+		}
+
+		if err := cfg.pushValue(value, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if embedName != "" {
+		return nil, fmt.Errorf("missing closing tag for <%s>", embedName)
+	}
+
+	return cfg, nil
+}
+
+// splitFields splits a directive into its fields following the rules of
+// OpenVPN: fields are separated by spaces, unless quoted with double or
+// single quotes, and a backslash only escapes a backslash, a double quote or
+// a space. Single quoted values are taken as is.
+func splitFields(line string) ([]string, error) {
+	fields := []string{}
+
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			break
+		}
+
+		if line[0] == '\'' {
+			end := strings.IndexByte(line[1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated quoted value")
+			}
+			fields = append(fields, line[1:end+1])
+			line = line[end+2:]
+			continue
+		}
+
+		var field strings.Builder
+
+		quoted := line[0] == '"'
+		closed := !quoted
+
+		i := 0
+		if quoted {
+			i = 1
+		}
+		for ; i < len(line); i++ {
+			c := line[i]
+			if c == '\\' && i+1 < len(line) && strings.IndexByte(escapable, line[i+1]) >= 0 {
+				i++
+				field.WriteByte(line[i])
+				continue
+			}
+			if quoted && c == '"' {
+				closed = true
+				i++
+				break
+			}
+			if !quoted && (c == ' ' || c == '\t') {
+				break
+			}
+			field.WriteByte(c)
+		}
+		if !closed {
+			return nil, errors.New("unterminated quoted value")
+		}
+
+		fields = append(fields, field.String())
+		line = line[i:]
+	}
+
+	return fields, nil
+}
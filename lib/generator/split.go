@@ -0,0 +1,51 @@
+package generator
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// File is an inline block moved out of a configuration by Split.
+type File struct {
+	Directive string
+	Name      string
+	Data      []byte
+}
+
+// Split returns a copy of cfg in which every inline block is replaced by a
+// directive that references the file returned by name, along with the
+// contents of those files. The original configuration is left untouched.
+//
+// Inline pkcs12 blocks are base64 encoded while pkcs12 files are binary, so
+// they're decoded.
+func (cfg *Config) Split(name func(directive string) string) (*Config, []File, error) {
+	split := cfg.Clone()
+	files := []File{}
+
+	for _, directive := range split.Names() {
+		if _, ok := split.Embedded(directive); !ok {
+			continue
+		}
+
+		file := name(directive)
+		buf, err := split.Unembed(directive, file)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		data := append(buf, '\n')
+		if directive == "pkcs12" {
+			if data, err = base64.StdEncoding.DecodeString(string(buf)); err != nil {
+				return nil, nil, fmt.Errorf("invalid pkcs12 block: %v", err)
+			}
+		}
+
+		files = append(files, File{
+			Directive: directive,
+			Name:      file,
+			Data:      data,
+		})
+	}
+
+	return split, files, nil
+}
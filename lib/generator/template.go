@@ -2,7 +2,6 @@ package generator
 
 import (
 	"bytes"
-	"fmt"
 	"strings"
 
 	"text/template"
@@ -35,11 +34,31 @@ func toString(v []byte) string {
 	return string(v)
 }
 
+// escapable are the characters a backslash escapes in OpenVPN
+// configuration files.
+const escapable = `\" `
+
+// Quote returns value in double quotes, escaped the way OpenVPN reads it.
+func Quote(value string) string {
+	var buf strings.Builder
+
+	buf.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' || value[i] == '"' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(value[i])
+	}
+	buf.WriteByte('"')
+
+	return buf.String()
+}
+
 func quotedValues(values []string) string {
 	res := []string{}
 
 	for i := range values {
-		res = append(res, fmt.Sprintf("%q", values[i]))
+		res = append(res, Quote(values[i]))
 	}
 
 	return strings.Join(res, " ")
@@ -0,0 +1,268 @@
+// Package htpasswd reads and writes htpasswd-style files with bcrypt or
+// argon2id password hashes, used to check the credentials clients send with
+// auth-user-pass.
+//
+// Every line holds a user name and a hash separated by a colon, followed by
+// the TOTP secret of users enrolled in a second factor:
+//
+//	alice:$2y$10$...
+//	bob:$argon2id$v=19$m=65536,t=3,p=4$...$...:JBSWY3DPEHPK3PXP...
+package htpasswd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUnknownUser = errors.New("unknown user")
+	ErrMismatch    = errors.New("wrong password")
+	ErrUnsupported = errors.New("unsupported hash, use bcrypt or argon2id")
+)
+
+// Parameters of new argon2id hashes.
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// DefaultCost is the cost of new bcrypt hashes.
+const DefaultCost = 10
+
+// dummyHash is compared when the user is unknown, so unknown users take as
+// long as wrong passwords.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), DefaultCost)
+
+type entry struct {
+	user string
+	hash string
+	totp string
+}
+
+// File is the contents of an htpasswd file.
+type File struct {
+	entries []entry
+}
+
+// Read parses an htpasswd file.
+func Read(r io.Reader) (*File, error) {
+	f := &File{}
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("line %d: expecting user:hash", lineNo)
+		}
+		e := entry{user: parts[0], hash: parts[1]}
+		if len(parts) > 2 {
+			e.totp = parts[2]
+		}
+		f.entries = append(f.entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Load reads the htpasswd file name, a missing file holds no users.
+func Load(name string) (*File, error) {
+	fp, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &File{}, nil
+		}
+		return nil, err
+	}
+	defer fp.Close()
+
+	return Read(fp)
+}
+
+// Users returns the name of every user.
+func (f *File) Users() []string {
+	users := make([]string, 0, len(f.entries))
+	for _, e := range f.entries {
+		users = append(users, e.user)
+	}
+	return users
+}
+
+// Verify checks the password of user.
+func (f *File) Verify(user string, password string) error {
+	for _, e := range f.entries {
+		if e.user == user {
+			return Compare(e.hash, password)
+		}
+	}
+
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+	return ErrUnknownUser
+}
+
+// TOTP returns the TOTP secret of user, empty if the user is not enrolled.
+func (f *File) TOTP(user string) string {
+	for _, e := range f.entries {
+		if e.user == user {
+			return e.totp
+		}
+	}
+	return ""
+}
+
+// SetTOTP sets the TOTP secret of user, an empty secret removes it.
+func (f *File) SetTOTP(user string, secret string) error {
+	if strings.ContainsAny(secret, ":\n") {
+		return errors.New("invalid TOTP secret")
+	}
+
+	for i := range f.entries {
+		if f.entries[i].user == user {
+			f.entries[i].totp = secret
+			return nil
+		}
+	}
+	return ErrUnknownUser
+}
+
+// Set adds user, or replaces its hash if it exists.
+func (f *File) Set(user string, hash string) error {
+	if user == "" || strings.ContainsAny(user, ":\n") {
+		return fmt.Errorf("invalid user name %q", user)
+	}
+
+	for i := range f.entries {
+		if f.entries[i].user == user {
+			f.entries[i].hash = hash
+			return nil
+		}
+	}
+
+	f.entries = append(f.entries, entry{user: user, hash: hash})
+	return nil
+}
+
+// Remove deletes user, it reports whether the user existed.
+func (f *File) Remove(user string) bool {
+	for i := range f.entries {
+		if f.entries[i].user == user {
+			f.entries = append(f.entries[:i], f.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Bytes returns the contents of the file.
+func (f *File) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, e := range f.entries {
+		fmt.Fprintf(&buf, "%s:%s", e.user, e.hash)
+		if e.totp != "" {
+			fmt.Fprintf(&buf, ":%s", e.totp)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+// HashBcrypt returns the bcrypt hash of password.
+func HashBcrypt(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// HashArgon2 returns the argon2id hash of password in the PHC string
+// format.
+func HashArgon2(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Compare checks password against a bcrypt or argon2id hash.
+func Compare(hash string, password string) error {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if err == bcrypt.ErrMismatchedHashAndPassword {
+				return ErrMismatch
+			}
+			return err
+		}
+		return nil
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return compareArgon2(hash, password)
+	}
+	return ErrUnsupported
+}
+
+func compareArgon2(hash string, password string) error {
+	// $argon2id$v=19$m=65536,t=3,p=4$salt$key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return errors.New("unsupported argon2id version")
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return errors.New("invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return errors.New("invalid argon2id salt")
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return errors.New("invalid argon2id key")
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return ErrMismatch
+	}
+
+	return nil
+}
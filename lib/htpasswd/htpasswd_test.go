@@ -0,0 +1,73 @@
+package htpasswd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	bcryptHash, err := HashBcrypt("s3cret", 4)
+	assert.NoError(t, err)
+
+	argon2Hash, err := HashArgon2("hunter2")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(argon2Hash, "$argon2id$v=19$m=65536,t=3,p=4$"))
+
+	f, err := Read(strings.NewReader("# users\nbob:" + bcryptHash + "\n\ncarol:" + argon2Hash + "\ndave:plaintext\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bob", "carol", "dave"}, f.Users())
+
+	assert.NoError(t, f.Verify("bob", "s3cret"))
+	assert.Equal(t, ErrMismatch, f.Verify("bob", "hunter2"))
+
+	assert.NoError(t, f.Verify("carol", "hunter2"))
+	assert.Equal(t, ErrMismatch, f.Verify("carol", "s3cret"))
+
+	assert.Equal(t, ErrUnsupported, f.Verify("dave", "plaintext"))
+	assert.Equal(t, ErrUnknownUser, f.Verify("eve", "s3cret"))
+}
+
+func TestApacheBcrypt(t *testing.T) {
+	hash, err := HashBcrypt("secret", 4)
+	assert.NoError(t, err)
+
+	// Apache htpasswd -B writes the $2y$ prefix.
+	f, err := Read(strings.NewReader("alice:$2y$" + strings.TrimPrefix(hash, "$2a$") + "\n"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, f.Verify("alice", "secret"))
+	assert.Equal(t, ErrMismatch, f.Verify("alice", "Secret"))
+}
+
+func TestSet(t *testing.T) {
+	f := &File{}
+
+	assert.NoError(t, f.Set("alice", "$2y$a"))
+	assert.NoError(t, f.Set("bob", "$2y$b"))
+	assert.NoError(t, f.Set("alice", "$2y$c"))
+	assert.Error(t, f.Set("al:ice", "$2y$d"))
+
+	assert.Equal(t, "alice:$2y$c\nbob:$2y$b\n", string(f.Bytes()))
+
+	assert.True(t, f.Remove("alice"))
+	assert.False(t, f.Remove("alice"))
+	assert.Equal(t, "bob:$2y$b\n", string(f.Bytes()))
+
+	assert.NoError(t, f.SetTOTP("bob", "JBSWY3DPEHPK3PXP"))
+	assert.Equal(t, ErrUnknownUser, f.SetTOTP("alice", "JBSWY3DPEHPK3PXP"))
+	assert.NoError(t, f.Set("bob", "$2y$e"))
+	assert.Equal(t, "bob:$2y$e:JBSWY3DPEHPK3PXP\n", string(f.Bytes()))
+
+	f, err := Read(strings.NewReader(string(f.Bytes())))
+	assert.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", f.TOTP("bob"))
+	assert.Equal(t, "", f.TOTP("alice"))
+
+	assert.NoError(t, f.SetTOTP("bob", ""))
+	assert.Equal(t, "bob:$2y$e\n", string(f.Bytes()))
+
+	_, err = Read(strings.NewReader("nohash\n"))
+	assert.Error(t, err)
+}
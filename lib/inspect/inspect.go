@@ -0,0 +1,353 @@
+// Package inspect decodes the certificates, keys and parameters used by
+// OpenVPN into a human and machine readable summary.
+package inspect
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/xiam/openvpn-config-generator/lib/certtool"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+// Kinds of objects Decode knows about.
+const (
+	KindCertificate  = "certificate"
+	KindPrivateKey   = "private-key"
+	KindCRL          = "crl"
+	KindDHParameters = "dh-parameters"
+	KindStaticKey    = "static-key"
+	KindPKCS12       = "pkcs12"
+)
+
+const staticKeyType = "OpenVPN Static key V1"
+
+// Revoked is an entry of a certificate revocation list.
+type Revoked struct {
+	Serial    string    `json:"serial"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+// Object describes a single decoded PEM block.
+type Object struct {
+	Kind   string `json:"kind"`
+	Source string `json:"source,omitempty"`
+
+	Subject     string     `json:"subject,omitempty"`
+	Issuer      string     `json:"issuer,omitempty"`
+	Serial      string     `json:"serial,omitempty"`
+	NotBefore   *time.Time `json:"not_before,omitempty"`
+	NotAfter    *time.Time `json:"not_after,omitempty"`
+	IsCA        bool       `json:"is_ca,omitempty"`
+	KeyUsage    []string   `json:"key_usage,omitempty"`
+	ExtKeyUsage []string   `json:"ext_key_usage,omitempty"`
+	DNSNames    []string   `json:"dns_names,omitempty"`
+	Signature   string     `json:"signature_algorithm,omitempty"`
+
+	KeyType string `json:"key_type,omitempty"`
+	KeyBits int    `json:"key_bits,omitempty"`
+
+	ThisUpdate *time.Time `json:"this_update,omitempty"`
+	NextUpdate *time.Time `json:"next_update,omitempty"`
+	Revoked    []Revoked  `json:"revoked,omitempty"`
+
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// File inspects the contents of a file, which can be either a PEM file or an
+// OpenVPN configuration file with inline blocks.
+func File(buf []byte) ([]*Object, error) {
+	if cfg, err := generator.Parse(buf); err == nil {
+		objects, err := Config(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if len(objects) > 0 {
+			return objects, nil
+		}
+	}
+
+	return Decode(buf)
+}
+
+// Config inspects every inline block of cfg.
+func Config(cfg *generator.Config) ([]*Object, error) {
+	objects := []*Object{}
+
+	seen := map[string]bool{}
+	for _, name := range cfg.Names() {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		buf, ok := cfg.Embedded(name)
+		if !ok {
+			continue
+		}
+
+		// Credentials are not certificates nor keys.
+		if name == "auth-user-pass" {
+			continue
+		}
+
+		// PKCS#12 bundles are password protected.
+		if name == "pkcs12" {
+			objects = append(objects, &Object{Kind: KindPKCS12, Source: name})
+			continue
+		}
+
+		decoded, err := Decode(buf)
+		if err != nil {
+			return nil, fmt.Errorf("<%s>: %v", name, err)
+		}
+		for i := range decoded {
+			decoded[i].Source = name
+		}
+		objects = append(objects, decoded...)
+	}
+
+	return objects, nil
+}
+
+// Decode inspects every PEM block found in buf.
+func Decode(buf []byte) ([]*Object, error) {
+	objects := []*Object{}
+
+	for {
+		block, rest := pem.Decode(buf)
+		if block == nil {
+			break
+		}
+
+		var (
+			obj *Object
+			err error
+		)
+
+		switch block.Type {
+		case "CERTIFICATE":
+			obj, err = decodeCertificate(block.Bytes)
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			obj, err = decodePrivateKey(block.Bytes)
+		case "X509 CRL":
+			obj, err = decodeCRL(block.Bytes)
+		case "DH PARAMETERS":
+			obj, err = decodeDHParams(block.Bytes)
+		case staticKeyType:
+			obj, err = decodeStaticKey(buf[:len(buf)-len(rest)])
+		default:
+			err = fmt.Errorf("unsupported PEM block %q", block.Type)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, obj)
+		buf = rest
+	}
+
+	if len(objects) == 0 {
+		return nil, errors.New("no PEM data found")
+	}
+
+	return objects, nil
+}
+
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+
+	parts := make([]string, len(sum))
+	for i := range sum {
+		parts[i] = fmt.Sprintf("%02X", sum[i])
+	}
+
+	return strings.Join(parts, ":")
+}
+
+func serial(n *big.Int) string {
+	return strings.ToUpper(n.Text(16))
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func keyInfo(pub interface{}) (string, int) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return "RSA", k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	}
+	return "unknown", 0
+}
+
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "Digital Signature"},
+	{x509.KeyUsageContentCommitment, "Non Repudiation"},
+	{x509.KeyUsageKeyEncipherment, "Key Encipherment"},
+	{x509.KeyUsageDataEncipherment, "Data Encipherment"},
+	{x509.KeyUsageKeyAgreement, "Key Agreement"},
+	{x509.KeyUsageCertSign, "Certificate Sign"},
+	{x509.KeyUsageCRLSign, "CRL Sign"},
+	{x509.KeyUsageEncipherOnly, "Encipher Only"},
+	{x509.KeyUsageDecipherOnly, "Decipher Only"},
+}
+
+// ExtKeyUsageName returns the name OpenVPN uses (e.g. in remote-cert-eku) for
+// an extended key usage.
+func ExtKeyUsageName(usage x509.ExtKeyUsage) string {
+	switch usage {
+	case x509.ExtKeyUsageServerAuth:
+		return "TLS Web Server Authentication"
+	case x509.ExtKeyUsageClientAuth:
+		return "TLS Web Client Authentication"
+	case x509.ExtKeyUsageCodeSigning:
+		return "Code Signing"
+	case x509.ExtKeyUsageEmailProtection:
+		return "E-mail Protection"
+	case x509.ExtKeyUsageTimeStamping:
+		return "Time Stamping"
+	case x509.ExtKeyUsageOCSPSigning:
+		return "OCSP Signing"
+	case x509.ExtKeyUsageAny:
+		return "Any Extended Key Usage"
+	}
+	return fmt.Sprintf("unknown (%d)", usage)
+}
+
+// Certificate summarizes a parsed certificate.
+func Certificate(cert *x509.Certificate) *Object {
+	obj := &Object{
+		Kind:        KindCertificate,
+		Subject:     cert.Subject.String(),
+		Issuer:      cert.Issuer.String(),
+		Serial:      serial(cert.SerialNumber),
+		NotBefore:   timePtr(cert.NotBefore),
+		NotAfter:    timePtr(cert.NotAfter),
+		IsCA:        cert.IsCA,
+		DNSNames:    cert.DNSNames,
+		Signature:   cert.SignatureAlgorithm.String(),
+		Fingerprint: fingerprint(cert.Raw),
+	}
+
+	obj.KeyType, obj.KeyBits = keyInfo(cert.PublicKey)
+
+	for _, ku := range keyUsageNames {
+		if cert.KeyUsage&ku.usage != 0 {
+			obj.KeyUsage = append(obj.KeyUsage, ku.name)
+		}
+	}
+
+	for _, eku := range cert.ExtKeyUsage {
+		obj.ExtKeyUsage = append(obj.ExtKeyUsage, ExtKeyUsageName(eku))
+	}
+
+	return obj
+}
+
+func decodeCertificate(der []byte) (*Object, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return Certificate(cert), nil
+}
+
+func decodePrivateKey(der []byte) (*Object, error) {
+	key, err := certtool.ParsePrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &Object{Kind: KindPrivateKey}
+	obj.KeyType, obj.KeyBits = keyInfo(key.Public())
+
+	if spki, err := x509.MarshalPKIXPublicKey(key.Public()); err == nil {
+		obj.Fingerprint = fingerprint(spki)
+	}
+
+	return obj, nil
+}
+
+func decodeCRL(der []byte) (*Object, error) {
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &Object{
+		Kind:        KindCRL,
+		Issuer:      crl.Issuer.String(),
+		ThisUpdate:  timePtr(crl.ThisUpdate),
+		NextUpdate:  timePtr(crl.NextUpdate),
+		Signature:   crl.SignatureAlgorithm.String(),
+		Fingerprint: fingerprint(der),
+		Revoked:     []Revoked{},
+	}
+
+	if crl.Number != nil {
+		obj.Serial = serial(crl.Number)
+	}
+
+	for _, entry := range crl.RevokedCertificateEntries {
+		obj.Revoked = append(obj.Revoked, Revoked{
+			Serial:    serial(entry.SerialNumber),
+			RevokedAt: entry.RevocationTime,
+		})
+	}
+
+	return obj, nil
+}
+
+func decodeDHParams(der []byte) (*Object, error) {
+	p, _, err := certtool.ParseDHParams(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{
+		Kind:    KindDHParameters,
+		KeyType: "DH",
+		KeyBits: p.BitLen(),
+	}, nil
+}
+
+func decodeStaticKey(buf []byte) (*Object, error) {
+	begin := []byte("-----BEGIN " + staticKeyType + "-----")
+	end := []byte("-----END " + staticKeyType + "-----")
+
+	start := bytes.Index(buf, begin)
+	stop := bytes.Index(buf, end)
+	if start < 0 || stop < start {
+		return nil, errors.New("malformed static key")
+	}
+
+	key, err := hex.DecodeString(string(bytes.Join(bytes.Fields(buf[start+len(begin):stop]), nil)))
+	if err != nil {
+		return nil, fmt.Errorf("malformed static key: %v", err)
+	}
+
+	return &Object{
+		Kind:    KindStaticKey,
+		KeyType: "OpenVPN",
+		KeyBits: len(key) * 8,
+	}, nil
+}
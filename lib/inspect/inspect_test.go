@@ -0,0 +1,79 @@
+package inspect
+
+import (
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ovpncfg "github.com/xiam/openvpn-config-generator"
+	"github.com/xiam/openvpn-config-generator/lib/certtool"
+)
+
+var dhParameters = []byte(`-----BEGIN DH PARAMETERS-----
+MIGHAoGBAP+ReUM54jfDGAspfG9K0V1VCxc+bZQlNHBg//bgPbIaEQRGRaVbXlLX
+1c5PiSwbagn7wt4odyig3om4PfzPlPRsI0jSy8Ml1BmkDLDi045FT4LOCzoBzFeJ
+7Qfr2mQG8gRqXAJhsic7uJbpHyXGVnime8+hWVtUOUB501+ktaxjAgEC
+-----END DH PARAMETERS-----`)
+
+func TestDecode(t *testing.T) {
+	caCert, caKey, err := certtool.BuildCA()
+	assert.NoError(t, err)
+
+	staticKey, err := ovpncfg.GenOpenVPNStaticKey()
+	assert.NoError(t, err)
+
+	buf := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert})
+	buf = append(buf, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: caKey})...)
+	buf = append(buf, dhParameters...)
+	buf = append(buf, '\n')
+	buf = append(buf, staticKey...)
+
+	objects, err := Decode(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(objects))
+
+	assert.Equal(t, KindCertificate, objects[0].Kind)
+	assert.True(t, objects[0].IsCA)
+	assert.Equal(t, "RSA", objects[0].KeyType)
+	assert.Equal(t, 3072, objects[0].KeyBits)
+	assert.Equal(t, objects[0].Subject, objects[0].Issuer)
+
+	assert.Equal(t, KindPrivateKey, objects[1].Kind)
+	assert.Equal(t, 3072, objects[1].KeyBits)
+
+	assert.Equal(t, KindDHParameters, objects[2].Kind)
+	assert.Equal(t, 1024, objects[2].KeyBits)
+
+	assert.Equal(t, KindStaticKey, objects[3].Kind)
+	assert.Equal(t, 2048, objects[3].KeyBits)
+}
+
+func TestFile(t *testing.T) {
+	caCert, caKey, err := certtool.BuildCA()
+	assert.NoError(t, err)
+
+	clientCert, _, err := certtool.BuildClientCertificate(caCert, caKey, "client.local")
+	assert.NoError(t, err)
+
+	config, err := ovpncfg.NewClientConfig()
+	assert.NoError(t, err)
+
+	config.MustEmbed("ca", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert}))
+	config.MustEmbed("cert", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCert}))
+	config.MustEmbed("auth-user-pass", []byte("alice\nsecret"))
+
+	buf, err := config.Compile()
+	assert.NoError(t, err)
+
+	objects, err := File(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(objects))
+
+	assert.Equal(t, "ca", objects[0].Source)
+	assert.Equal(t, "cert", objects[1].Source)
+	assert.Equal(t, []string{"TLS Web Client Authentication"}, objects[1].ExtKeyUsage)
+	assert.Contains(t, objects[1].Subject, "CN=client.local")
+
+	_, err = File([]byte("verb 3"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,271 @@
+// Package k8s renders a server configuration as Kubernetes manifests: a
+// ConfigMap with the configuration, a Secret with the certificates and keys
+// and, optionally, a Deployment and a Service that run the server.
+//
+// The Deployment enables IP forwarding with the net.ipv4.ip_forward sysctl,
+// which kubelets only allow with --allowed-unsafe-sysctls.
+package k8s
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/xiam/openvpn-config-generator/lib/archive"
+	"github.com/xiam/openvpn-config-generator/lib/container"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+// Paths the volumes are mounted on, they can't be nested as ConfigMap and
+// Secret volumes are read-only.
+const (
+	ConfigDir = "/etc/openvpn/config"
+	PKIDir    = "/etc/openvpn/pki"
+	StateDir  = "/var/lib/openvpn"
+)
+
+// ConfigFile is the key of the configuration in the ConfigMap.
+const ConfigFile = "server.conf"
+
+// Options of the manifests.
+type Options struct {
+	// Name of the resources, it must be a valid DNS label.
+	Name string
+
+	// Namespace of the resources, the one of kubectl if empty.
+	Namespace string
+
+	// Deployment adds a Deployment and a Service that run the server.
+	Deployment bool
+
+	// Image the server runs on, it must be Alpine based.
+	Image string
+
+	// ServiceType is the type of the Service, LoadBalancer if empty.
+	ServiceType string
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// fileDirectives reference certificates and keys, they must be embedded to
+// end up in the Secret.
+var fileDirectives = []string{"ca", "cert", "key", "pkcs12", "tls-crypt", "tls-auth", "secret"}
+
+var funcs = template.FuncMap{
+	"indent": func(n int, s string) string {
+		pad := strings.Repeat(" ", n)
+		lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+		for i := range lines {
+			if lines[i] != "" {
+				lines[i] = pad + lines[i]
+			}
+		}
+		return strings.Join(lines, "\n")
+	},
+	"upper": strings.ToUpper,
+}
+
+var manifestsTemplate = template.Must(template.New("k8s").Funcs(funcs).Parse(`{{define "metadata"}}metadata:
+  name: {{.Name}}{{.Suffix}}
+{{- if .Namespace}}
+  namespace: {{.Namespace}}
+{{- end}}
+  labels:
+    app.kubernetes.io/name: {{.Name}}
+{{- end -}}
+apiVersion: v1
+kind: ConfigMap
+{{template "metadata" .WithSuffix "-config"}}
+data:
+{{- range .ConfigFiles}}
+  {{.Key}}: |
+{{indent 4 .Value}}
+{{- end}}
+---
+apiVersion: v1
+kind: Secret
+{{template "metadata" .WithSuffix "-pki"}}
+type: Opaque
+data:
+{{- range .Secrets}}
+  {{.Key}}: {{.Value}}
+{{- end}}
+{{- if .Deployment}}
+---
+apiVersion: apps/v1
+kind: Deployment
+{{template "metadata" .WithSuffix ""}}
+spec:
+  replicas: 1
+  strategy:
+    type: Recreate
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: {{.Name}}
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/name: {{.Name}}
+    spec:
+      securityContext:
+        sysctls:
+          - name: net.ipv4.ip_forward
+            value: "1"
+      containers:
+        - name: openvpn
+          image: {{.Image}}
+          command: ["/bin/sh", "{{.ConfigDir}}/entrypoint.sh", "{{.ConfigDir}}/{{.ConfigFile}}"]
+          env:
+            - name: PORT
+              value: "{{.Env.Port}}"
+            - name: PROTO
+              value: "{{.Env.Proto}}"
+            - name: NETWORK
+              value: "{{.Env.Network}}"
+            - name: NETWORK_MASK
+              value: "{{.Env.NetworkMask}}"
+          ports:
+            - name: openvpn
+              containerPort: {{.Env.Port}}
+              protocol: {{upper .Env.Proto}}
+          securityContext:
+            capabilities:
+              add: ["NET_ADMIN", "MKNOD"]
+          volumeMounts:
+            - name: config
+              mountPath: {{.ConfigDir}}
+              readOnly: true
+            - name: pki
+              mountPath: {{.PKIDir}}
+              readOnly: true
+            - name: state
+              mountPath: {{.StateDir}}
+      volumes:
+        - name: config
+          configMap:
+            name: {{.Name}}-config
+        - name: pki
+          secret:
+            secretName: {{.Name}}-pki
+            defaultMode: 0400
+        - name: state
+          emptyDir: {}
+---
+apiVersion: v1
+kind: Service
+{{template "metadata" .WithSuffix ""}}
+spec:
+  type: {{.ServiceType}}
+  selector:
+    app.kubernetes.io/name: {{.Name}}
+  ports:
+    - name: openvpn
+      port: {{.Env.Port}}
+      targetPort: openvpn
+      protocol: {{upper .Env.Proto}}
+{{- end}}
+`))
+
+type entry struct {
+	Key   string
+	Value string
+}
+
+type manifests struct {
+	Options
+	Suffix      string
+	ConfigFiles []entry
+	Secrets     []entry
+	Env         *container.Env
+	ConfigDir   string
+	ConfigFile  string
+	PKIDir      string
+	StateDir    string
+}
+
+func (m manifests) WithSuffix(suffix string) manifests {
+	m.Suffix = suffix
+	return m
+}
+
+// Manifests returns the manifests for a server configuration, separated by
+// "---" so they can be applied with kubectl apply -f.
+func Manifests(config *generator.Config, opts Options) ([]byte, error) {
+	if !validName.MatchString(opts.Name) {
+		return nil, fmt.Errorf("%q is not a valid name, use lowercase letters, digits and dashes", opts.Name)
+	}
+	if opts.Namespace != "" && !validName.MatchString(opts.Namespace) {
+		return nil, fmt.Errorf("%q is not a valid namespace", opts.Namespace)
+	}
+	if opts.Image == "" {
+		opts.Image = container.DefaultImage
+	}
+	if opts.ServiceType == "" {
+		opts.ServiceType = "LoadBalancer"
+	}
+
+	for _, directive := range fileDirectives {
+		if _, embedded := config.Embedded(directive); config.Has(directive) && !embedded {
+			return nil, fmt.Errorf("%s references a file, it must be embedded in the configuration", directive)
+		}
+	}
+
+	env, err := container.EnvFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// OpenVPN runs from the state directory, so the certificates and keys
+	// are referenced by absolute path.
+	profile, files, err := config.Split(func(directive string) string {
+		return path.Join(PKIDir, archive.FileName(directive, opts.Name))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := profile.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	m := manifests{
+		Options: opts,
+		Env:     env,
+		ConfigFiles: []entry{
+			{Key: ConfigFile, Value: string(buf)},
+		},
+		ConfigDir:  ConfigDir,
+		ConfigFile: ConfigFile,
+		PKIDir:     PKIDir,
+		StateDir:   StateDir,
+	}
+
+	if opts.Deployment {
+		// The configuration is read-only, so OpenVPN runs from the state
+		// directory where it keeps ipp.txt and the client-config-dir.
+		m.ConfigFiles = append(m.ConfigFiles, entry{Key: "entrypoint.sh", Value: string(container.Entrypoint(StateDir))})
+	}
+
+	for _, file := range files {
+		m.Secrets = append(m.Secrets, entry{
+			Key:   path.Base(file.Name),
+			Value: base64.StdEncoding.EncodeToString(file.Data),
+		})
+	}
+	sort.Slice(m.Secrets, func(i, j int) bool {
+		return m.Secrets[i].Key < m.Secrets[j].Key
+	})
+
+	var out bytes.Buffer
+	if err := manifestsTemplate.Execute(&out, m); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
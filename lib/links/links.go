@@ -0,0 +1,253 @@
+// Package links publishes client profiles behind single-use, time-limited
+// tokens.
+//
+// Published profiles are kept in the links directory of the work directory,
+// one file per token. Files are named after the SHA-256 hash of the token so
+// that listing the directory doesn't reveal valid tokens. A profile is
+// deleted as soon as it's redeemed or found to be expired.
+package links
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xiam/openvpn-config-generator/lib/output"
+)
+
+const (
+	// Dir is the name of the directory, relative to the work directory,
+	// that holds published profiles.
+	Dir = "links"
+
+	// DefaultTTL is how long a link stays valid unless told otherwise.
+	DefaultTTL = 24 * time.Hour
+
+	tokenSize = 32
+	extension = ".json"
+	claimed   = ".claimed"
+)
+
+var (
+	// ErrInvalid is returned when a token does not exist or was already
+	// used.
+	ErrInvalid = errors.New("link is invalid or was already used")
+
+	// ErrExpired is returned when a token exists but is no longer valid.
+	ErrExpired = errors.New("link has expired")
+)
+
+// Link is a published profile.
+type Link struct {
+	Name    string    `json:"name"`
+	Expires time.Time `json:"expires"`
+	Profile []byte    `json:"profile"`
+}
+
+// Expired reports whether the link is no longer valid at t.
+func (l *Link) Expired(t time.Time) bool {
+	return !t.Before(l.Expires)
+}
+
+// Store keeps published profiles in a directory.
+type Store struct {
+	dir string
+}
+
+// Open returns a Store that keeps its files in the links directory of
+// workdir, the directory is created if needed.
+func Open(workdir string) (*Store, error) {
+	dir := filepath.Join(workdir, Dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) path(token string) (string, error) {
+	if len(token) != hex.EncodedLen(tokenSize) {
+		return "", ErrInvalid
+	}
+	if _, err := hex.DecodeString(token); err != nil {
+		return "", ErrInvalid
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+extension), nil
+}
+
+func readLink(file string) (*Link, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var link Link
+	if err := json.Unmarshal(buf, &link); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	return &link, nil
+}
+
+// Publish stores profile and returns a token that can be redeemed once
+// within ttl.
+func (s *Store) Publish(name string, profile []byte, ttl time.Duration) (string, time.Time, error) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	if _, err := s.Purge(); err != nil {
+		log.Printf("links: failed to purge expired links: %v", err)
+	}
+
+	buf := make([]byte, tokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(buf)
+
+	link := Link{
+		Name:    name,
+		Expires: time.Now().Add(ttl).UTC().Truncate(time.Second),
+		Profile: profile,
+	}
+
+	data, err := json.Marshal(link)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	file, err := s.path(token)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// Profiles hold private keys.
+	if err := output.Default.WriteFile(file, data, output.PrivatePerm); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, link.Expires, nil
+}
+
+// Pending reports whether token can still be redeemed.
+func (s *Store) Pending(token string) bool {
+	file, err := s.path(token)
+	if err != nil {
+		return false
+	}
+
+	link, err := readLink(file)
+	if err != nil {
+		return false
+	}
+
+	return !link.Expired(time.Now())
+}
+
+// Redeem returns the profile published under token and deletes it, a token
+// can only be redeemed once.
+func (s *Store) Redeem(token string) (*Link, error) {
+	file, err := s.path(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Renaming is atomic, only one of several concurrent requests for the
+	// same token can succeed.
+	if err := os.Rename(file, file+claimed); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrInvalid
+		}
+		return nil, err
+	}
+	defer os.Remove(file + claimed)
+
+	link, err := readLink(file + claimed)
+	if err != nil {
+		return nil, err
+	}
+
+	if link.Expired(time.Now()) {
+		return nil, ErrExpired
+	}
+
+	return link, nil
+}
+
+// Purge deletes expired links and returns how many were deleted.
+func (s *Store) Purge() (int, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	n := 0
+	for _, fi := range files {
+		if !strings.HasSuffix(fi.Name(), extension) {
+			continue
+		}
+
+		file := filepath.Join(s.dir, fi.Name())
+		link, err := readLink(file)
+		if err != nil {
+			return n, err
+		}
+
+		if link.Expired(now) {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// Handler returns an http.Handler that redeems the token found in the last
+// element of the request path and serves the profile as a .ovpn download.
+func (s *Store) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+			return
+		}
+
+		link, err := s.Redeem(path.Base(r.URL.Path))
+		switch err {
+		case nil:
+		case ErrInvalid:
+			http.Error(w, "This link is invalid or was already used.", http.StatusNotFound)
+			return
+		case ErrExpired:
+			http.Error(w, "This link has expired.", http.StatusGone)
+			return
+		default:
+			log.Printf("links: failed to redeem link: %v", err)
+			http.Error(w, "Could not read profile.", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("links: client %q downloaded its profile", link.Name)
+
+		w.Header().Set("Content-Type", "application/x-openvpn-profile")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.ovpn"`, link.Name))
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(link.Profile)
+	})
+}
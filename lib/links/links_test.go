@@ -0,0 +1,105 @@
+package links
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "links")
+	assert.NoError(t, err)
+
+	store, err := Open(dir)
+	assert.NoError(t, err)
+
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func TestRedeem(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+
+	token, expires, err := store.Publish("laptop", []byte("client\n"), time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, expires.After(time.Now()))
+	assert.True(t, store.Pending(token))
+
+	files, err := ioutil.ReadDir(store.dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(files))
+	assert.Equal(t, os.FileMode(0600), files[0].Mode().Perm())
+	assert.NotContains(t, files[0].Name(), token)
+
+	link, err := store.Redeem(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "laptop", link.Name)
+	assert.Equal(t, []byte("client\n"), link.Profile)
+	assert.False(t, store.Pending(token))
+
+	_, err = store.Redeem(token)
+	assert.Equal(t, ErrInvalid, err)
+
+	_, err = store.Redeem("../../etc/passwd")
+	assert.Equal(t, ErrInvalid, err)
+
+	files, err = ioutil.ReadDir(store.dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(files))
+}
+
+func TestExpiry(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+
+	token, _, err := store.Publish("laptop", []byte("client\n"), time.Nanosecond)
+	assert.NoError(t, err)
+
+	time.Sleep(time.Millisecond)
+
+	_, err = store.Redeem(token)
+	assert.Equal(t, ErrExpired, err)
+
+	_, _, err = store.Publish("phone", []byte("client\n"), time.Nanosecond)
+	assert.NoError(t, err)
+
+	time.Sleep(time.Millisecond)
+
+	n, err := store.Purge()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestHandler(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(store.Handler())
+	defer ts.Close()
+
+	token, _, err := store.Publish("laptop", []byte("client\n"), time.Hour)
+	assert.NoError(t, err)
+
+	res, err := http.Get(ts.URL + "/download/" + token)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "client\n", string(body))
+	assert.Contains(t, res.Header.Get("Content-Disposition"), `filename="laptop.ovpn"`)
+
+	res, err = http.Get(ts.URL + "/download/" + token)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	_, err = os.Stat(filepath.Join(store.dir, token+extension))
+	assert.True(t, os.IsNotExist(err))
+}
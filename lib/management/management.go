@@ -0,0 +1,453 @@
+// Package management implements a client for the OpenVPN management
+// interface.
+package management
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const passwordPrompt = "ENTER PASSWORD:"
+
+// ErrClosed is returned by commands issued after the connection was closed.
+var ErrClosed = errors.New("management connection closed")
+
+// ErrTimeout is returned by commands that were not answered in time. The
+// connection is closed, since the late reply would be read as the answer of
+// the next command.
+var ErrTimeout = errors.New("timeout waiting for response")
+
+// Event is a real-time notification sent by OpenVPN, such as ">BYTECOUNT:"
+// or ">CLIENT:CONNECT".
+type Event struct {
+	// Type is the notification name, e.g. "BYTECOUNT_CLI" or "CLIENT".
+	Type string
+
+	// Data is whatever follows the colon.
+	Data string
+
+	// Env holds the environment of ">CLIENT:" notifications.
+	Env map[string]string
+}
+
+// Options tune Dial.
+type Options struct {
+	// Password answers the interface's password prompt, if any.
+	Password string
+
+	// Timeout bounds how long dialing and each command can take. A command
+	// that times out closes the connection.
+	Timeout time.Duration
+}
+
+// Client is a connection to the management interface. It is safe for
+// concurrent use, commands are sent one at a time.
+type Client struct {
+	conn    net.Conn
+	timeout time.Duration
+
+	mu     sync.Mutex
+	lines  chan string
+	done   chan struct{}
+	err    error
+	failed error
+
+	events chan Event
+}
+
+// Dial connects to the management interface listening on address. network is
+// either "tcp" or "unix".
+func Dial(network string, address string, opts *Options) (*Client, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	conn, err := net.DialTimeout(network, address, dialTimeout(opts.Timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := NewClient(conn, opts)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func dialTimeout(timeout time.Duration) time.Duration {
+	if timeout == 0 {
+		return 10 * time.Second
+	}
+	return timeout
+}
+
+// NewClient wraps an already established connection, answering the password
+// prompt if needed.
+func NewClient(conn net.Conn, opts *Options) (*Client, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	r := bufio.NewReader(conn)
+
+	if opts.Timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(opts.Timeout))
+	}
+
+	prompt, err := r.Peek(len(passwordPrompt))
+	if err != nil {
+		return nil, err
+	}
+
+	if string(prompt) == passwordPrompt {
+		if _, err := r.Discard(len(passwordPrompt)); err != nil {
+			return nil, err
+		}
+		if _, err := fmt.Fprintf(conn, "%s\n", opts.Password); err != nil {
+			return nil, err
+		}
+
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "SUCCESS:") {
+			return nil, fmt.Errorf("authentication failed: %s", line)
+		}
+	}
+
+	conn.SetReadDeadline(time.Time{})
+
+	c := &Client{
+		conn:    conn,
+		timeout: opts.Timeout,
+		lines:   make(chan string, 64),
+		done:    make(chan struct{}),
+		events:  make(chan Event, 64),
+	}
+
+	go c.readLoop(r)
+
+	return c, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *Client) readLoop(r *bufio.Reader) {
+	defer close(c.done)
+	defer close(c.events)
+
+	var client *Event
+
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			c.err = err
+			return
+		}
+
+		if !strings.HasPrefix(line, ">") {
+			c.lines <- line
+			continue
+		}
+
+		event := parseEvent(line)
+
+		if event.Type != "CLIENT" {
+			c.emit(event)
+			continue
+		}
+
+		// >CLIENT notifications are followed by their environment, one
+		// variable per line, and end with >CLIENT:ENV,END.
+		if strings.HasPrefix(event.Data, "ENV,") {
+			if client == nil {
+				continue
+			}
+			env := strings.TrimPrefix(event.Data, "ENV,")
+			if env == "END" {
+				c.emit(*client)
+				client = nil
+				continue
+			}
+			kv := strings.SplitN(env, "=", 2)
+			if len(kv) == 2 {
+				client.Env[kv[0]] = kv[1]
+			}
+			continue
+		}
+
+		event.Env = map[string]string{}
+		if strings.HasPrefix(event.Data, "ADDRESS,") {
+			c.emit(event)
+			continue
+		}
+		client = &event
+	}
+}
+
+func (c *Client) emit(event Event) {
+	select {
+	case c.events <- event:
+	default:
+		// Nobody is listening, drop the oldest event.
+		select {
+		case <-c.events:
+		default:
+		}
+		c.events <- event
+	}
+}
+
+func parseEvent(line string) Event {
+	parts := strings.SplitN(line[1:], ":", 2)
+	event := Event{Type: parts[0]}
+	if len(parts) > 1 {
+		event.Data = parts[1]
+	}
+	return event
+}
+
+// Events returns a channel where real-time notifications are delivered. The
+// channel is buffered and drops old events if nobody is reading, it is closed
+// when the connection ends.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Close terminates the connection, after the command in progress if any.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.failed == nil {
+		c.conn.Write([]byte("quit\n"))
+	}
+	return c.conn.Close()
+}
+
+func (c *Client) readResponse() (string, error) {
+	var timeout <-chan time.Time
+	if c.timeout > 0 {
+		timeout = time.After(c.timeout)
+	}
+
+	select {
+	case line := <-c.lines:
+		return line, nil
+	case <-c.done:
+		if c.err != nil && c.err != io.EOF {
+			return "", c.err
+		}
+		return "", ErrClosed
+	case <-timeout:
+		c.failed = ErrTimeout
+		c.conn.Close()
+		return "", ErrTimeout
+	}
+}
+
+func (c *Client) send(lines ...string) error {
+	if c.failed != nil {
+		return c.failed
+	}
+	if c.timeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	_, err := io.WriteString(c.conn, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// Command sends a command that is answered with a single SUCCESS or ERROR
+// line and returns the message that follows SUCCESS.
+func (c *Client) Command(lines ...string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.send(lines...); err != nil {
+		return "", err
+	}
+
+	line, err := c.readResponse()
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case strings.HasPrefix(line, "SUCCESS:"):
+		return strings.TrimSpace(strings.TrimPrefix(line, "SUCCESS:")), nil
+	case strings.HasPrefix(line, "ERROR:"):
+		return "", errors.New(strings.TrimSpace(strings.TrimPrefix(line, "ERROR:")))
+	}
+
+	return "", fmt.Errorf("unexpected response: %q", line)
+}
+
+// MultiLineCommand sends a command whose output spans several lines and ends
+// with END, and returns those lines.
+func (c *Client) MultiLineCommand(command string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.send(command); err != nil {
+		return nil, err
+	}
+
+	lines := []string{}
+	for {
+		line, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if line == "END" {
+			return lines, nil
+		}
+		if len(lines) == 0 && strings.HasPrefix(line, "ERROR:") {
+			return nil, errors.New(strings.TrimSpace(strings.TrimPrefix(line, "ERROR:")))
+		}
+		lines = append(lines, line)
+	}
+}
+
+// Version returns the version of OpenVPN and of the management interface.
+func (c *Client) Version() ([]string, error) {
+	return c.MultiLineCommand("version")
+}
+
+// Status returns the output of "status 2", a list of connected clients in the
+// same format OpenVPN uses for its status file.
+func (c *Client) Status() ([]byte, error) {
+	lines, err := c.MultiLineCommand("status 2")
+	if err != nil {
+		return nil, err
+	}
+	// MultiLineCommand strips the END line that terminates status files.
+	lines = append(lines, "END")
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// Kill disconnects the client with the given common name or real address
+// (ip:port).
+func (c *Client) Kill(target string) error {
+	_, err := c.Command("kill " + target)
+	return err
+}
+
+// ByteCount asks OpenVPN to send BYTECOUNT notifications every interval
+// seconds, zero turns notifications off.
+func (c *Client) ByteCount(interval int) error {
+	_, err := c.Command("bytecount " + strconv.Itoa(interval))
+	return err
+}
+
+// ClientAuth authorizes a client waiting on management-client-auth,
+// optionally pushing config lines to it.
+func (c *Client) ClientAuth(cid uint64, kid uint64, config ...string) error {
+	if len(config) == 0 {
+		_, err := c.Command(fmt.Sprintf("client-auth-nt %d %d", cid, kid))
+		return err
+	}
+
+	lines := append([]string{fmt.Sprintf("client-auth %d %d", cid, kid)}, config...)
+	lines = append(lines, "END")
+
+	_, err := c.Command(lines...)
+	return err
+}
+
+// ClientDeny rejects a client waiting on management-client-auth. reason is
+// logged by the server, clientReason is sent to the client.
+func (c *Client) ClientDeny(cid uint64, kid uint64, reason string, clientReason string) error {
+	command := fmt.Sprintf("client-deny %d %d %s", cid, kid, quote(reason))
+	if clientReason != "" {
+		command += " " + quote(clientReason)
+	}
+	_, err := c.Command(command)
+	return err
+}
+
+// ClientKill disconnects the client with the given client ID.
+func (c *Client) ClientKill(cid uint64) error {
+	_, err := c.Command(fmt.Sprintf("client-kill %d", cid))
+	return err
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// ByteCount is the payload of a BYTECOUNT or BYTECOUNT_CLI notification.
+type ByteCount struct {
+	ClientID      uint64
+	BytesReceived uint64
+	BytesSent     uint64
+}
+
+// ParseByteCount decodes a BYTECOUNT or BYTECOUNT_CLI event.
+func ParseByteCount(event Event) (*ByteCount, error) {
+	fields := strings.Split(event.Data, ",")
+
+	var values []uint64
+	for _, field := range fields {
+		n, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed %s event: %q", event.Type, event.Data)
+		}
+		values = append(values, n)
+	}
+
+	switch {
+	case event.Type == "BYTECOUNT" && len(values) == 2:
+		return &ByteCount{BytesReceived: values[0], BytesSent: values[1]}, nil
+	case event.Type == "BYTECOUNT_CLI" && len(values) == 3:
+		return &ByteCount{ClientID: values[0], BytesReceived: values[1], BytesSent: values[2]}, nil
+	}
+
+	return nil, fmt.Errorf("malformed %s event: %q", event.Type, event.Data)
+}
+
+// ClientRequest is the payload of a >CLIENT:CONNECT or >CLIENT:REAUTH event.
+type ClientRequest struct {
+	Reason   string
+	ClientID uint64
+	KeyID    uint64
+	Env      map[string]string
+}
+
+// ParseClientRequest decodes a >CLIENT:CONNECT, >CLIENT:REAUTH,
+// >CLIENT:ESTABLISHED or >CLIENT:DISCONNECT event.
+func ParseClientRequest(event Event) (*ClientRequest, error) {
+	fields := strings.Split(event.Data, ",")
+	if event.Type != "CLIENT" || len(fields) < 2 {
+		return nil, fmt.Errorf("malformed %s event: %q", event.Type, event.Data)
+	}
+
+	req := &ClientRequest{Reason: fields[0], Env: event.Env}
+
+	var err error
+	if req.ClientID, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+		return nil, fmt.Errorf("malformed client ID: %q", fields[1])
+	}
+	if len(fields) > 2 {
+		if req.KeyID, err = strconv.ParseUint(fields[2], 10, 64); err != nil {
+			return nil, fmt.Errorf("malformed key ID: %q", fields[2])
+		}
+	}
+
+	return req, nil
+}
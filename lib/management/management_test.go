@@ -0,0 +1,213 @@
+package management
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeServer mimics the OpenVPN management interface closely enough to
+// exercise the client.
+type fakeServer struct {
+	listener net.Listener
+	password string
+	received chan string
+}
+
+func newFakeServer(t *testing.T, password string) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	s := &fakeServer{
+		listener: listener,
+		password: password,
+		received: make(chan string, 16),
+	}
+	go s.serve()
+
+	return s
+}
+
+func (s *fakeServer) Close() {
+	s.listener.Close()
+}
+
+func (s *fakeServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	write := func(lines ...string) {
+		fmt.Fprint(conn, strings.Join(lines, "\r\n")+"\r\n")
+	}
+
+	if s.password != "" {
+		fmt.Fprint(conn, passwordPrompt)
+		line, _ := r.ReadString('\n')
+		if strings.TrimSpace(line) != s.password {
+			write("ERROR: bad password")
+			return
+		}
+		write("SUCCESS: password is correct")
+	}
+
+	write(">INFO:OpenVPN Management Interface Version 5 -- type 'help' for more info")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		s.received <- line
+
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "status":
+			write(
+				"TITLE,OpenVPN 2.5.1 x86_64-pc-linux-gnu",
+				"TIME,2021-01-01 00:00:00,1609459200",
+				"HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Virtual IPv6 Address,Bytes Received,Bytes Sent,Connected Since,Connected Since (time_t),Username,Client ID,Peer ID",
+				"CLIENT_LIST,laptop,192.0.2.1:1194,10.9.0.2,,100,200,2021-01-01 00:00:00,1609459200,UNDEF,3,0",
+				"END",
+			)
+		case "kill":
+			if fields[1] == "laptop" {
+				write("SUCCESS: common name 'laptop' found, 1 client(s) killed")
+			} else {
+				write("ERROR: common name '" + fields[1] + "' not found")
+			}
+		case "bytecount":
+			write("SUCCESS: bytecount interval changed")
+			write(">BYTECOUNT_CLI:3,1024,2048")
+		case "client-auth":
+			for {
+				line, _ := r.ReadString('\n')
+				line = strings.TrimSpace(line)
+				s.received <- line
+				if line == "END" {
+					break
+				}
+			}
+			write("SUCCESS: client-auth command succeeded")
+		case "client-auth-nt", "client-deny", "client-kill":
+			write(fmt.Sprintf("SUCCESS: %s command succeeded", fields[0]))
+		case "connect":
+			write(
+				">CLIENT:CONNECT,7,1",
+				">CLIENT:ENV,common_name=laptop",
+				">CLIENT:ENV,untrusted_ip=192.0.2.1",
+				">CLIENT:ENV,END",
+			)
+			write("SUCCESS: ok")
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+			write("SUCCESS: slow")
+		case "quit":
+			return
+		default:
+			write("ERROR: unknown command, enter 'help' for more options")
+		}
+	}
+}
+
+func waitEvent(t *testing.T, c *Client, eventType string) Event {
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-c.Events():
+			if event.Type == eventType {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for %s event", eventType)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := newFakeServer(t, "secret")
+	defer server.Close()
+
+	c, err := Dial("tcp", server.listener.Addr().String(), &Options{Password: "secret", Timeout: 2 * time.Second})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	status, err := c.Status()
+	assert.NoError(t, err)
+	assert.Contains(t, string(status), "CLIENT_LIST,laptop,192.0.2.1:1194")
+	assert.True(t, strings.HasSuffix(string(status), "\nEND\n"))
+	assert.Equal(t, "status 2", <-server.received)
+
+	assert.NoError(t, c.Kill("laptop"))
+	<-server.received
+
+	err = c.Kill("desktop")
+	assert.Error(t, err)
+	assert.Equal(t, "common name 'desktop' not found", err.Error())
+	<-server.received
+
+	assert.NoError(t, c.ByteCount(5))
+	assert.Equal(t, "bytecount 5", <-server.received)
+
+	bc, err := ParseByteCount(waitEvent(t, c, "BYTECOUNT_CLI"))
+	assert.NoError(t, err)
+	assert.Equal(t, &ByteCount{ClientID: 3, BytesReceived: 1024, BytesSent: 2048}, bc)
+
+	assert.NoError(t, c.ClientAuth(7, 1, "push \"route 10.0.0.0 255.0.0.0\""))
+	assert.Equal(t, "client-auth 7 1", <-server.received)
+	assert.Equal(t, `push "route 10.0.0.0 255.0.0.0"`, <-server.received)
+	assert.Equal(t, "END", <-server.received)
+
+	assert.NoError(t, c.ClientAuth(7, 1))
+	assert.Equal(t, "client-auth-nt 7 1", <-server.received)
+
+	assert.NoError(t, c.ClientDeny(7, 1, "bad user", "go away"))
+	assert.Equal(t, `client-deny 7 1 "bad user" "go away"`, <-server.received)
+
+	_, err = c.Command("connect")
+	assert.NoError(t, err)
+	<-server.received
+
+	req, err := ParseClientRequest(waitEvent(t, c, "CLIENT"))
+	assert.NoError(t, err)
+	assert.Equal(t, "CONNECT", req.Reason)
+	assert.Equal(t, uint64(7), req.ClientID)
+	assert.Equal(t, uint64(1), req.KeyID)
+	assert.Equal(t, "laptop", req.Env["common_name"])
+
+	_, err = c.MultiLineCommand("nonsense")
+	assert.Error(t, err)
+}
+
+func TestBadPassword(t *testing.T) {
+	server := newFakeServer(t, "secret")
+	defer server.Close()
+
+	_, err := Dial("tcp", server.listener.Addr().String(), &Options{Password: "wrong", Timeout: 2 * time.Second})
+	assert.Error(t, err)
+}
+
+func TestTimeout(t *testing.T) {
+	server := newFakeServer(t, "")
+	defer server.Close()
+
+	c, err := Dial("tcp", server.listener.Addr().String(), &Options{Timeout: 50 * time.Millisecond})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Command("slow")
+	assert.Equal(t, ErrTimeout, err)
+
+	// The late answer to "slow" must not be read as the answer to "kill".
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, ErrTimeout, c.Kill("desktop"))
+}
@@ -0,0 +1,450 @@
+// Package networkmanager converts client profiles into NetworkManager
+// keyfiles (.nmconnection) for the OpenVPN plugin.
+//
+// NetworkManager doesn't read inline blocks, so certificates and keys are
+// moved to their own files and the keyfile references them by absolute path.
+package networkmanager
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/xiam/openvpn-config-generator/lib/archive"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+// ServiceType is the D-Bus name of the NetworkManager OpenVPN plugin.
+const ServiceType = "org.freedesktop.NetworkManager.openvpn"
+
+// Secret flags, see nm-settings-nmcli(5).
+const (
+	flagNone       = "0"
+	flagAgentOwned = "1"
+	flagNotSaved   = "2"
+	flagNotNeeded  = "4"
+)
+
+// Connection types of the OpenVPN plugin.
+const (
+	connectionTLS         = "tls"
+	connectionPassword    = "password"
+	connectionPasswordTLS = "password-tls"
+	connectionStaticKey   = "static-key"
+)
+
+// Options of a keyfile.
+type Options struct {
+	// ID is the name of the connection, it's also used to name the
+	// extracted files.
+	ID string
+
+	// UUID of the connection, a random one is used if empty.
+	UUID string
+
+	// Dir is the absolute path the extracted files are installed to.
+	Dir string
+
+	// Username for profiles with auth-user-pass, the one of an
+	// <auth-user-pass> block if empty.
+	Username string
+
+	// Password for profiles with auth-user-pass, the one of an
+	// <auth-user-pass> block if empty. NetworkManager asks for it if both
+	// are empty.
+	Password string
+
+	// CertPass is the password of the private key or PKCS#12 file.
+	CertPass string
+}
+
+// File is a certificate or key referenced by the keyfile.
+type File = generator.File
+
+// Connection is a NetworkManager keyfile and the files it references.
+type Connection struct {
+	Keyfile []byte
+	Files   []File
+
+	// Warnings lists directives of the profile NetworkManager can't
+	// honor, they're left out of the keyfile.
+	Warnings []string
+}
+
+// FileName returns the name of the keyfile of a connection.
+func FileName(id string) string {
+	return id + ".nmconnection"
+}
+
+// ignored directives are either implied by the OpenVPN plugin or handled by
+// NetworkManager itself.
+var ignored = map[string]bool{
+	"client":       true,
+	"nobind":       true,
+	"pull":         true,
+	"tls-client":   true,
+	"persist-key":  true,
+	"persist-tun":  true,
+	"resolv-retry": true,
+	"verb":         true,
+	"auth-nocache": true,
+	"setenv":       true,
+}
+
+// pathKeys maps directives that reference files to the vpn.data keys
+// holding them.
+var pathKeys = map[string]string{
+	"ca":           "ca",
+	"cert":         "cert",
+	"key":          "key",
+	"tls-crypt":    "tls-crypt",
+	"tls-crypt-v2": "tls-crypt-v2",
+	"extra-certs":  "extra-certs",
+	"crl-verify":   "crl-verify-file",
+}
+
+// valueKeys maps directives that take a single value to the vpn.data keys
+// holding it.
+var valueKeys = map[string]string{
+	"cipher":          "cipher",
+	"data-ciphers":    "data-ciphers",
+	"auth":            "auth",
+	"keysize":         "keysize",
+	"tls-cipher":      "tls-cipher",
+	"tls-version-min": "tls-version-min",
+	"tls-version-max": "tls-version-max",
+	"remote-cert-tls": "remote-cert-tls",
+	"ns-cert-type":    "ns-cert-type",
+	"tun-mtu":         "tunnel-mtu",
+	"fragment":        "fragment-size",
+	"ping":            "ping",
+	"ping-exit":       "ping-exit",
+	"ping-restart":    "ping-restart",
+	"reneg-sec":       "reneg-seconds",
+	"max-routes":      "max-routes",
+}
+
+// flagKeys maps directives without values to the vpn.data keys enabling
+// them.
+var flagKeys = map[string]string{
+	"float":         "float",
+	"remote-random": "remote-random",
+	"tun-ipv6":      "tun-ipv6",
+	"mssfix":        "mssfix",
+}
+
+// Convert returns the NetworkManager keyfile for a client profile.
+func Convert(config *generator.Config, opts Options) (*Connection, error) {
+	if opts.ID == "" {
+		return nil, errors.New("missing connection ID")
+	}
+	if !path.IsAbs(opts.Dir) {
+		return nil, fmt.Errorf("%q is not an absolute path", opts.Dir)
+	}
+
+	id := opts.UUID
+	if id == "" {
+		var err error
+		if id, err = newUUID(); err != nil {
+			return nil, err
+		}
+	}
+
+	// NetworkManager can't read credentials from a file, embedded ones
+	// go to the keyfile instead.
+	if block, ok := config.Embedded("auth-user-pass"); ok {
+		lines := strings.Split(string(block), "\n")
+		if opts.Username == "" {
+			opts.Username = strings.TrimRight(lines[0], "\r")
+		}
+		if opts.Password == "" && len(lines) > 1 {
+			opts.Password = strings.TrimRight(lines[1], "\r")
+		}
+		config = config.Clone()
+		if err := config.Remove("auth-user-pass"); err != nil {
+			return nil, err
+		}
+		config.MustEnable("auth-user-pass")
+	}
+
+	profile, files, err := config.Split(func(directive string) string {
+		return archive.FileName(directive, opts.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &Connection{Files: files}
+
+	data := map[string]string{}
+	secrets := map[string]string{}
+
+	proto := "udp"
+	if values, ok := profile.Get("proto"); ok && len(values) > 0 {
+		proto = values[0]
+	}
+
+	port := "1194"
+	if values, ok := profile.Get("port"); ok && len(values) > 0 {
+		port = values[0]
+	}
+	if values, ok := profile.Get("rport"); ok && len(values) > 0 {
+		port = values[0]
+	}
+
+	if strings.HasPrefix(proto, "tcp") {
+		data["proto-tcp"] = "yes"
+	}
+
+	remotes := []string{}
+	for _, remote := range profile.GetAll("remote") {
+		if len(remote) == 0 {
+			continue
+		}
+		host, remotePort := remote[0], port
+		if len(remote) > 1 {
+			remotePort = remote[1]
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		entry := host + ":" + remotePort
+		if len(remote) > 2 {
+			entry += ":" + remote[2]
+		}
+		remotes = append(remotes, entry)
+	}
+	if len(remotes) == 0 {
+		return nil, errors.New("missing remote")
+	}
+	data["remote"] = strings.Join(remotes, ", ")
+
+	file := func(values []string) string {
+		if len(values) == 0 {
+			return ""
+		}
+		return path.Join(opts.Dir, values[0])
+	}
+
+	var hasCert, hasSecret, hasUserPass bool
+	for _, name := range profile.Names() {
+		args, _ := profile.Get(name)
+
+		switch name {
+		case "proto", "port", "rport", "remote":
+			// Handled above.
+		case "dev":
+			if len(args) > 0 {
+				devType := strings.TrimRight(args[0], "0123456789")
+				data["dev-type"] = devType
+				if args[0] != devType {
+					data["dev"] = args[0]
+				}
+			}
+		case "dev-type":
+			if len(args) > 0 {
+				data["dev-type"] = args[0]
+			}
+		case "cert", "key":
+			hasCert = true
+			data[name] = file(args)
+		case "pkcs12":
+			// The OpenVPN plugin expects the PKCS#12 file in both cert
+			// and key.
+			hasCert = true
+			data["cert"] = file(args)
+			data["key"] = file(args)
+		case "tls-auth":
+			data["ta"] = file(args)
+			if len(args) > 1 {
+				data["ta-dir"] = args[1]
+			}
+		case "key-direction":
+			if len(args) > 0 {
+				data["ta-dir"] = args[0]
+			}
+		case "secret":
+			hasSecret = true
+			data["static-key"] = file(args)
+			if len(args) > 1 {
+				data["static-key-direction"] = args[1]
+			}
+		case "auth-user-pass":
+			hasUserPass = true
+			if len(args) > 0 {
+				conn.Warnings = append(conn.Warnings, "auth-user-pass: the credentials file is ignored, NetworkManager keeps them in its secret store")
+			}
+		case "comp-lzo":
+			data["comp-lzo"] = "adaptive"
+			if len(args) > 0 {
+				data["comp-lzo"] = args[0]
+			}
+		case "compress":
+			data["compress"] = "yes"
+			if len(args) > 0 {
+				data["compress"] = args[0]
+			}
+		case "verify-x509-name":
+			if len(args) > 0 {
+				kind := "subject"
+				if len(args) > 1 {
+					kind = args[1]
+				}
+				data["verify-x509-name"] = kind + ":" + args[0]
+			}
+		default:
+			switch {
+			case ignored[name]:
+			case pathKeys[name] != "":
+				data[pathKeys[name]] = file(args)
+			case valueKeys[name] != "" && len(args) > 0:
+				data[valueKeys[name]] = args[0]
+			case flagKeys[name] != "":
+				data[flagKeys[name]] = "yes"
+			default:
+				conn.Warnings = append(conn.Warnings, fmt.Sprintf("%s: not supported by NetworkManager, ignored", name))
+			}
+		}
+	}
+
+	if data["dev-type"] == "" {
+		data["dev-type"] = "tun"
+	}
+
+	switch {
+	case hasSecret:
+		data["connection-type"] = connectionStaticKey
+	case hasCert && hasUserPass:
+		data["connection-type"] = connectionPasswordTLS
+	case hasCert:
+		data["connection-type"] = connectionTLS
+	case hasUserPass:
+		data["connection-type"] = connectionPassword
+	default:
+		return nil, errors.New("the profile has neither a certificate nor auth-user-pass")
+	}
+
+	if hasCert {
+		switch {
+		case opts.CertPass != "":
+			data["cert-pass-flags"] = flagNone
+			secrets["cert-pass"] = opts.CertPass
+		case encryptedKey(config):
+			data["cert-pass-flags"] = flagNotSaved
+		default:
+			data["cert-pass-flags"] = flagNotNeeded
+		}
+	}
+
+	if hasUserPass {
+		if opts.Username != "" {
+			data["username"] = opts.Username
+		}
+		if opts.Password != "" {
+			data["password-flags"] = flagNone
+			secrets["password"] = opts.Password
+		} else {
+			data["password-flags"] = flagAgentOwned
+		}
+	}
+
+	var buf bytes.Buffer
+
+	writeSection(&buf, "connection", [][2]string{
+		{"id", opts.ID},
+		{"uuid", id},
+		{"type", "vpn"},
+		{"autoconnect", "false"},
+	})
+
+	vpn := [][2]string{}
+	for _, key := range sortedKeys(data) {
+		vpn = append(vpn, [2]string{key, data[key]})
+	}
+	vpn = append(vpn, [2]string{"service-type", ServiceType})
+	writeSection(&buf, "vpn", vpn)
+
+	if len(secrets) > 0 {
+		vpnSecrets := [][2]string{}
+		for _, key := range sortedKeys(secrets) {
+			vpnSecrets = append(vpnSecrets, [2]string{key, secrets[key]})
+		}
+		writeSection(&buf, "vpn-secrets", vpnSecrets)
+	}
+
+	writeSection(&buf, "ipv4", [][2]string{
+		{"method", "auto"},
+	})
+
+	writeSection(&buf, "ipv6", [][2]string{
+		{"addr-gen-mode", "stable-privacy"},
+		{"method", "auto"},
+	})
+
+	conn.Keyfile = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+
+	return conn, nil
+}
+
+// encryptedKey reports whether the private key of config is encrypted and
+// needs a password.
+func encryptedKey(config *generator.Config) bool {
+	if config.Has("pkcs12") {
+		return true
+	}
+	key, ok := config.Embedded("key")
+	return ok && bytes.Contains(key, []byte("ENCRYPTED"))
+}
+
+func writeSection(buf *bytes.Buffer, name string, entries [][2]string) {
+	fmt.Fprintf(buf, "[%s]\n", name)
+	for _, entry := range entries {
+		fmt.Fprintf(buf, "%s=%s\n", entry[0], escape(entry[1]))
+	}
+	buf.WriteString("\n")
+}
+
+// escape escapes a value the way GKeyFile does.
+func escape(s string) string {
+	var buf strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			buf.WriteString(`\\`)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == ' ' && i == 0:
+			buf.WriteString(`\s`)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
+
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,116 @@
+package output
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+type diskFS struct{}
+
+// Disk is the local file system.
+var Disk FS = diskFS{}
+
+func (diskFS) WriteFile(name string, data []byte, perm os.FileMode, overwrite bool) (err error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if overwrite {
+		return os.Rename(tmp.Name(), name)
+	}
+
+	// Linking fails if name exists, which makes the check and the write a
+	// single step.
+	err = os.Link(tmp.Name(), name)
+	if err == nil {
+		return nil
+	}
+	if os.IsExist(err) {
+		return &os.PathError{Op: "write", Path: name, Err: ErrExists}
+	}
+
+	// Hard links are not supported everywhere.
+	if _, err := os.Lstat(name); err == nil {
+		return &os.PathError{Op: "write", Path: name, Err: ErrExists}
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// MemFile is a file kept by MemFS.
+type MemFile struct {
+	Data []byte
+	Perm os.FileMode
+}
+
+// MemFS is an in-memory FS.
+type MemFS struct {
+	files map[string]MemFile
+	mu    sync.Mutex
+}
+
+// NewMemFS returns an empty MemFS.
+func NewMemFS() *MemFS {
+	return &MemFS{files: map[string]MemFile{}}
+}
+
+// WriteFile implements FS.
+func (m *MemFS) WriteFile(name string, data []byte, perm os.FileMode, overwrite bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	if _, ok := m.files[name]; ok && !overwrite {
+		return &os.PathError{Op: "write", Path: name, Err: ErrExists}
+	}
+
+	m.files[name] = MemFile{
+		Data: append([]byte(nil), data...),
+		Perm: perm,
+	}
+
+	return nil
+}
+
+// Open returns the file stored as name.
+func (m *MemFS) Open(name string) (MemFile, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, ok := m.files[filepath.Clean(name)]
+	return file, ok
+}
+
+// Names returns the names of all files, sorted.
+func (m *MemFS) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,126 @@
+// Package output writes generated files.
+//
+// Files are written atomically: data goes to a temporary file in the same
+// directory which is then renamed over the destination, so a crash never
+// leaves a partially written key or configuration behind. Private keys and
+// configurations that embed secrets are written with 0600 permissions and
+// existing keys are never overwritten unless forced.
+package output
+
+import (
+	"encoding/pem"
+	"errors"
+	"os"
+
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+const (
+	// PublicPerm is used for certificates and configurations without
+	// secrets.
+	PublicPerm os.FileMode = 0644
+
+	// PrivatePerm is used for keys and configurations with embedded
+	// secrets.
+	PrivatePerm os.FileMode = 0600
+)
+
+// ErrExists is returned when refusing to overwrite a file.
+var ErrExists = errors.New("file already exists")
+
+// secretDirectives are the inline blocks that hold private material.
+var secretDirectives = []string{
+	"auth-user-pass",
+	"key",
+	"pkcs12",
+	"secret",
+	"tls-auth",
+	"tls-crypt",
+	"tls-crypt-v2",
+}
+
+// FS is a file system files can be written to.
+type FS interface {
+	// WriteFile atomically replaces the contents of name. If overwrite is
+	// false and name exists, it fails with ErrExists.
+	WriteFile(name string, data []byte, perm os.FileMode, overwrite bool) error
+}
+
+// Writer writes certificates, keys and configurations to a FS.
+type Writer struct {
+	FS FS
+
+	// Force allows existing keys to be overwritten.
+	Force bool
+}
+
+// New returns a Writer for fs.
+func New(fs FS) *Writer {
+	return &Writer{FS: fs}
+}
+
+// Default writes to disk and never overwrites keys.
+var Default = New(Disk)
+
+// IsSecret reports whether the named directive holds private material when
+// embedded or kept in a separate file.
+func IsSecret(name string) bool {
+	for _, secret := range secretDirectives {
+		if name == secret {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSecrets reports whether config embeds private keys or static keys.
+func HasSecrets(config *generator.Config) bool {
+	for _, name := range secretDirectives {
+		if _, ok := config.Embedded(name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteFile writes data to name, replacing any existing file.
+func (w *Writer) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return w.FS.WriteFile(name, data, perm, true)
+}
+
+// WriteSecret writes data to name with private permissions. Existing files
+// are only replaced if Force is set.
+func (w *Writer) WriteSecret(name string, data []byte) error {
+	return w.FS.WriteFile(name, data, PrivatePerm, w.Force)
+}
+
+// WriteConfig compiles config and writes it to name.
+func (w *Writer) WriteConfig(config *generator.Config, name string) error {
+	buf, err := config.Compile()
+	if err != nil {
+		return err
+	}
+
+	perm := PublicPerm
+	if HasSecrets(config) {
+		perm = PrivatePerm
+	}
+
+	return w.WriteFile(name, buf, perm)
+}
+
+// WriteCert writes a DER encoded certificate to name in PEM format.
+func (w *Writer) WriteCert(cert []byte, name string) error {
+	return w.WriteFile(name, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: cert,
+	}), PublicPerm)
+}
+
+// WriteKey writes a DER encoded private key to name in PEM format.
+func (w *Writer) WriteKey(key []byte, name string) error {
+	return w.WriteSecret(name, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: key,
+	}))
+}
@@ -0,0 +1,157 @@
+package pki
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Status of a certificate in the index.
+type Status string
+
+const (
+	StatusValid   Status = "valid"
+	StatusRevoked Status = "revoked"
+	StatusExpired Status = "expired"
+)
+
+var statusFlags = map[string]Status{
+	"V": StatusValid,
+	"R": StatusRevoked,
+	"E": StatusExpired,
+}
+
+// Entry is a record of the certificate database, it follows the format of
+// OpenSSL's (and easy-rsa's) index.txt.
+type Entry struct {
+	Status     Status     `json:"status"`
+	CommonName string     `json:"common_name"`
+	Serial     string     `json:"serial"`
+	NotAfter   time.Time  `json:"not_after"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	File       string     `json:"-"`
+	Subject    string     `json:"-"`
+}
+
+func (e *Entry) flag() string {
+	for flag, status := range statusFlags {
+		if status == e.Status {
+			return flag
+		}
+	}
+	return "V"
+}
+
+const (
+	utcTimeLayout         = "060102150405Z"
+	generalizedTimeLayout = "20060102150405Z"
+)
+
+func formatIndexTime(t time.Time) string {
+	t = t.UTC()
+	if t.Year() >= 1950 && t.Year() < 2050 {
+		return t.Format(utcTimeLayout)
+	}
+	return t.Format(generalizedTimeLayout)
+}
+
+func parseIndexTime(value string) (time.Time, error) {
+	switch len(value) {
+	case len(generalizedTimeLayout):
+		return time.Parse(generalizedTimeLayout, value)
+	case len(utcTimeLayout):
+		return time.Parse(utcTimeLayout, value)
+	}
+	return time.Time{}, errors.New("invalid date")
+}
+
+func commonNameFromSubject(subject string) string {
+	for _, part := range strings.Split(subject, "/") {
+		if strings.HasPrefix(part, "CN=") {
+			return strings.TrimPrefix(part, "CN=")
+		}
+	}
+	return subject
+}
+
+// ReadIndex parses an index.txt database.
+func ReadIndex(r io.Reader) ([]Entry, error) {
+	entries := []Entry{}
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("line %d: expecting 6 fields, got %d", lineNo, len(fields))
+		}
+
+		status, ok := statusFlags[fields[0]]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown status %q", lineNo, fields[0])
+		}
+
+		notAfter, err := parseIndexTime(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		entry := Entry{
+			Status:     status,
+			CommonName: commonNameFromSubject(fields[5]),
+			Serial:     strings.ToUpper(fields[3]),
+			NotAfter:   notAfter,
+			File:       fields[4],
+			Subject:    fields[5],
+		}
+
+		if fields[2] != "" {
+			revokedAt, err := parseIndexTime(strings.SplitN(fields[2], ",", 2)[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			entry.RevokedAt = &revokedAt
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// WriteIndex writes entries in the index.txt format.
+func WriteIndex(w io.Writer, entries []Entry) error {
+	for _, entry := range entries {
+		revokedAt := ""
+		if entry.RevokedAt != nil {
+			revokedAt = formatIndexTime(*entry.RevokedAt)
+		}
+
+		file := entry.File
+		if file == "" {
+			file = "unknown"
+		}
+
+		subject := entry.Subject
+		if subject == "" {
+			subject = "/CN=" + entry.CommonName
+		}
+
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			entry.flag(), formatIndexTime(entry.NotAfter), revokedAt, entry.Serial, file, subject); err != nil {
+			return err
+		}
+	}
+	return nil
+}
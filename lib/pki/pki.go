@@ -0,0 +1,452 @@
+// Package pki keeps track of the certificates issued by a CA whose files live
+// in a work directory.
+//
+// The layout of the work directory matches the one used by the build-ca,
+// build-key and client-config commands: the CA is kept in ca.crt and ca.key,
+// the tls-crypt key in key.tlsauth and every client in {name}.crt and
+// {name}.key. Issued and revoked certificates are recorded in index.txt and
+// the revocation list is written to crl.pem.
+package pki
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	ovpncfg "github.com/xiam/openvpn-config-generator"
+	"github.com/xiam/openvpn-config-generator/lib/certtool"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+	"github.com/xiam/openvpn-config-generator/lib/output"
+)
+
+const (
+	caCertFile = "ca.crt"
+	caKeyFile  = "ca.key"
+	indexFile  = "index.txt"
+	crlFile    = "crl.pem"
+
+	// TLSCryptKeyFile is the name of the tls-crypt key in the work
+	// directory.
+	TLSCryptKeyFile = "key.tlsauth"
+
+	crlValidity = 180 * 24 * time.Hour
+)
+
+var (
+	// ErrNotFound is returned when a client does not exist.
+	ErrNotFound = errors.New("client not found")
+
+	// ErrExists is returned when creating a client that already exists.
+	ErrExists = errors.New("client already exists")
+
+	// ErrRevoked is returned when using a revoked client.
+	ErrRevoked = errors.New("client was revoked")
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,63}$`)
+
+var reservedNames = map[string]bool{
+	"ca":     true,
+	"server": true,
+	"crl":    true,
+	"index":  true,
+}
+
+// ValidateName checks that name can be used as both a common name and a file
+// name.
+func ValidateName(name string) error {
+	if !validName.MatchString(name) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid client name %q", name)
+	}
+	if reservedNames[strings.ToLower(name)] {
+		return fmt.Errorf("client name %q is reserved", name)
+	}
+	return nil
+}
+
+// Store manages the PKI kept in a work directory.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Open returns a Store for dir, which must hold a CA certificate and key.
+func Open(dir string) (*Store, error) {
+	s := &Store{dir: dir}
+
+	if _, _, err := s.ca(); err != nil {
+		return nil, fmt.Errorf("could not load CA: %v", err)
+	}
+
+	return s, nil
+}
+
+// Dir returns the work directory.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func readPEM(file string) ([]byte, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(buf)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", file)
+	}
+
+	return block.Bytes, nil
+}
+
+func (s *Store) ca() (cert []byte, key []byte, err error) {
+	if cert, err = readPEM(s.path(caCertFile)); err != nil {
+		return nil, nil, err
+	}
+	if key, err = readPEM(s.path(caKeyFile)); err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// CA returns the DER encoded CA certificate.
+func (s *Store) CA() ([]byte, error) {
+	return readPEM(s.path(caCertFile))
+}
+
+func (s *Store) readIndex() ([]Entry, error) {
+	fp, err := os.Open(s.path(indexFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Entry{}, nil
+		}
+		return nil, err
+	}
+	defer fp.Close()
+
+	return ReadIndex(fp)
+}
+
+func (s *Store) writeIndex(entries []Entry) error {
+	var buf bytes.Buffer
+	if err := WriteIndex(&buf, entries); err != nil {
+		return err
+	}
+	return output.Default.WriteFile(s.path(indexFile), buf.Bytes(), output.PublicPerm)
+}
+
+func findEntry(entries []Entry, name string) int {
+	// The most recent record wins, a name can be reused after revocation.
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].CommonName == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Lookup returns the most recent record of name in entries, nil if there is
+// none. Valid certificates past their expiration are marked as expired.
+func Lookup(entries []Entry, name string) *Entry {
+	i := findEntry(entries, name)
+	if i < 0 {
+		return nil
+	}
+
+	entry := entries[i]
+	refreshStatus(&entry, time.Now())
+
+	return &entry
+}
+
+func refreshStatus(entry *Entry, now time.Time) {
+	if entry.Status == StatusValid && now.After(entry.NotAfter) {
+		entry.Status = StatusExpired
+	}
+}
+
+// List returns every certificate recorded in the index.
+func (s *Store) List() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range entries {
+		refreshStatus(&entries[i], now)
+	}
+
+	return entries, nil
+}
+
+// Get returns the index record of a client.
+func (s *Store) Get(name string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := Lookup(entries, name)
+	if entry == nil {
+		return nil, ErrNotFound
+	}
+
+	return entry, nil
+}
+
+// CreateClient issues a client certificate, writes it to the work directory
+// and records it in the index.
+func (s *Store) CreateClient(name string) (*Entry, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	if i := findEntry(entries, name); i >= 0 && entries[i].Status != StatusRevoked {
+		return nil, ErrExists
+	}
+
+	caCert, caKey, err := s.ca()
+	if err != nil {
+		return nil, err
+	}
+
+	certDER, keyDER, err := certtool.BuildClientCertificate(caCert, caKey, name)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, err
+	}
+
+	certFile := name + ".crt"
+	if err := output.Default.WriteKey(keyDER, s.path(name+".key")); err != nil {
+		return nil, err
+	}
+	if err := output.Default.WriteCert(certDER, s.path(certFile)); err != nil {
+		return nil, err
+	}
+
+	entry := Entry{
+		Status:     StatusValid,
+		CommonName: name,
+		Serial:     strings.ToUpper(cert.SerialNumber.Text(16)),
+		NotAfter:   cert.NotAfter,
+		File:       certFile,
+	}
+
+	if err := s.writeIndex(append(entries, entry)); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// Credentials returns the DER encoded certificate and private key of a client
+// that was not revoked.
+func (s *Store) Credentials(name string) (cert []byte, key []byte, err error) {
+	entry, err := s.Get(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry.Status == StatusRevoked {
+		return nil, nil, ErrRevoked
+	}
+
+	if cert, err = readPEM(s.path(name + ".crt")); err != nil {
+		return nil, nil, err
+	}
+	if key, err = readPEM(s.path(name + ".key")); err != nil {
+		return nil, nil, err
+	}
+
+	return cert, key, nil
+}
+
+// Revoke marks a client as revoked, deletes its private key and regenerates
+// the revocation list.
+func (s *Store) Revoke(name string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	i := findEntry(entries, name)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	if entries[i].Status == StatusRevoked {
+		return nil, ErrRevoked
+	}
+
+	now := time.Now()
+	entries[i].Status = StatusRevoked
+	entries[i].RevokedAt = &now
+
+	if err := s.writeIndex(entries); err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(s.path(name + ".key")); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if _, err := s.writeCRL(entries); err != nil {
+		return nil, err
+	}
+
+	return &entries[i], nil
+}
+
+// CRL returns the PEM encoded revocation list, generating it if needed.
+func (s *Store) CRL() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if buf, err := ioutil.ReadFile(s.path(crlFile)); err == nil {
+		if block, _ := pem.Decode(buf); block != nil {
+			if crl, err := x509.ParseRevocationList(block.Bytes); err == nil && time.Now().Before(crl.NextUpdate) {
+				return buf, nil
+			}
+		}
+	}
+
+	entries, err := s.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.writeCRL(entries)
+}
+
+func (s *Store) writeCRL(entries []Entry) ([]byte, error) {
+	caCert, caKey, err := s.ca()
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := x509.ParseCertificate(caCert)
+	if err != nil {
+		return nil, err
+	}
+
+	// CAs created by earlier versions of build-ca have no key usage.
+	if ca.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return nil, errors.New("the CA certificate can't sign revocation lists, recreate it with build-ca")
+	}
+
+	signer, err := certtool.ParsePrivateKey(caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tpl := &x509.RevocationList{
+		Number:     big.NewInt(now.UnixNano()),
+		ThisUpdate: now,
+		NextUpdate: now.Add(crlValidity),
+	}
+
+	for _, entry := range entries {
+		if entry.Status != StatusRevoked {
+			continue
+		}
+
+		serial, ok := new(big.Int).SetString(entry.Serial, 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid serial number %q", entry.Serial)
+		}
+
+		revokedAt := now
+		if entry.RevokedAt != nil {
+			revokedAt = *entry.RevokedAt
+		}
+
+		tpl.RevokedCertificateEntries = append(tpl.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: revokedAt,
+		})
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, tpl, ca, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+	if err := output.Default.WriteFile(s.path(crlFile), buf, output.PublicPerm); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// ClientConfig builds an inline client configuration for name that connects
+// to remote.
+func (s *Store) ClientConfig(name string, remote string) (*generator.Config, error) {
+	cert, key, err := s.Credentials(name)
+	if err != nil {
+		return nil, err
+	}
+
+	caCert, err := s.CA()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsKey, err := ioutil.ReadFile(s.path(TLSCryptKeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("could not load TLS Authentication key: %v", err)
+	}
+
+	config, err := ovpncfg.NewClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	config.MustSet("remote", remote)
+
+	config.MustEmbed("ca", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert}))
+
+	config.MustEmbed("cert", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}))
+	config.MustEmbed("key", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: key}))
+
+	config.MustEmbed("tls-crypt", tlsKey)
+
+	return config, nil
+}
@@ -0,0 +1,133 @@
+package pki
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	ovpncfg "github.com/xiam/openvpn-config-generator"
+	"github.com/xiam/openvpn-config-generator/lib/certtool"
+	"github.com/xiam/openvpn-config-generator/lib/verify"
+)
+
+// newTestStore creates a work directory with a CA and a tls-crypt key.
+func newTestStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "pki")
+	assert.NoError(t, err)
+
+	caCert, caKey, err := certtool.BuildCA()
+	assert.NoError(t, err)
+
+	assert.NoError(t, ovpncfg.WriteCert(caCert, filepath.Join(dir, "ca.crt")))
+	assert.NoError(t, ovpncfg.WriteKey(caKey, filepath.Join(dir, "ca.key")))
+
+	staticKey, err := ovpncfg.GenOpenVPNStaticKey()
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, TLSCryptKeyFile), staticKey, 0600))
+
+	store, err := Open(dir)
+	assert.NoError(t, err)
+
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func TestStore(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+
+	entry, err := store.CreateClient("laptop")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusValid, entry.Status)
+	assert.Equal(t, "laptop", entry.CommonName)
+
+	_, err = store.CreateClient("laptop")
+	assert.Equal(t, ErrExists, err)
+
+	_, err = store.CreateClient("../etc/passwd")
+	assert.Error(t, err)
+
+	_, err = store.CreateClient("ca")
+	assert.Error(t, err)
+
+	_, err = store.CreateClient("phone")
+	assert.NoError(t, err)
+
+	entries, err := store.List()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	config, err := store.ClientConfig("laptop", "vpn.example.org")
+	assert.NoError(t, err)
+
+	report, err := verify.Config(config, nil)
+	assert.NoError(t, err)
+	assert.True(t, report.OK())
+
+	revoked, err := store.Revoke("laptop")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusRevoked, revoked.Status)
+	assert.NotNil(t, revoked.RevokedAt)
+
+	_, err = store.Revoke("laptop")
+	assert.Equal(t, ErrRevoked, err)
+
+	_, err = store.Revoke("desktop")
+	assert.Equal(t, ErrNotFound, err)
+
+	_, err = store.ClientConfig("laptop", "vpn.example.org")
+	assert.Equal(t, ErrRevoked, err)
+
+	crl, err := store.CRL()
+	assert.NoError(t, err)
+
+	report, err = verify.Config(config, &verify.Options{CRL: crl})
+	assert.NoError(t, err)
+	assert.False(t, report.OK())
+
+	// Names can be reused after revocation.
+	_, err = store.CreateClient("laptop")
+	assert.NoError(t, err)
+
+	entry, err = store.Get("laptop")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusValid, entry.Status)
+}
+
+func TestIndex(t *testing.T) {
+	revokedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Status: StatusValid, CommonName: "server", Serial: "01", NotAfter: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Status: StatusRevoked, CommonName: "old", Serial: "02", NotAfter: time.Date(2060, 1, 1, 0, 0, 0, 0, time.UTC), RevokedAt: &revokedAt},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteIndex(&buf, entries))
+	assert.Equal(t, "V\t300101000000Z\t\t01\tunknown\t/CN=server\nR\t20600101000000Z\t200101000000Z\t02\tunknown\t/CN=old\n", buf.String())
+
+	parsed, err := ReadIndex(strings.NewReader(buf.String()))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(parsed))
+	assert.Equal(t, "old", parsed[1].CommonName)
+	assert.Equal(t, StatusRevoked, parsed[1].Status)
+	assert.Equal(t, revokedAt, *parsed[1].RevokedAt)
+	assert.Equal(t, entries[1].NotAfter, parsed[1].NotAfter)
+
+	assert.Equal(t, StatusRevoked, Lookup(parsed, "old").Status)
+	assert.Nil(t, Lookup(parsed, "missing"))
+
+	parsed[0].NotAfter = time.Now().Add(-time.Hour)
+	assert.Equal(t, StatusExpired, Lookup(parsed, "server").Status)
+
+	_, err = ReadIndex(strings.NewReader("X\t300101000000Z\t\t01\tunknown\t/CN=server\n"))
+	assert.Error(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	_, err := Open(filepath.Join(os.TempDir(), "does-not-exist"))
+	assert.Error(t, err)
+}
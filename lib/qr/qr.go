@@ -0,0 +1,89 @@
+// Package qr renders QR codes for terminals and as PNG images, used to move
+// profiles and download links onto phones.
+package qr
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// ErrTooLarge is returned when content does not fit in a QR code.
+var ErrTooLarge = errors.New("content is too large for a QR code")
+
+const (
+	ansiReset = "\x1b[0m"
+
+	// Light modules are drawn white, dark modules black, regardless of the
+	// terminal's color scheme.
+	ansiFgDark  = "\x1b[30m"
+	ansiFgLight = "\x1b[97m"
+	ansiBgDark  = "\x1b[40m"
+	ansiBgLight = "\x1b[107m"
+
+	upperHalfBlock = "▀"
+)
+
+// Code is a QR code.
+type Code struct {
+	qr *qrcode.QRCode
+}
+
+// New encodes content. Medium error correction is used when content fits,
+// otherwise low error correction is used to make room.
+func New(content []byte) (*Code, error) {
+	for _, level := range []qrcode.RecoveryLevel{qrcode.Medium, qrcode.Low} {
+		qr, err := qrcode.New(string(content), level)
+		if err == nil {
+			return &Code{qr: qr}, nil
+		}
+	}
+	return nil, ErrTooLarge
+}
+
+// Bitmap returns the modules of the code including the quiet zone, true
+// means dark.
+func (c *Code) Bitmap() [][]bool {
+	return c.qr.Bitmap()
+}
+
+// WriteANSI draws the code to w with ANSI colors, two rows of modules per
+// line of text.
+func (c *Code) WriteANSI(w io.Writer) error {
+	bitmap := c.Bitmap()
+	bw := bufio.NewWriter(w)
+
+	for y := 0; y < len(bitmap); y += 2 {
+		last := ""
+		for x := range bitmap[y] {
+			top := bitmap[y][x]
+			bottom := false
+			if y+1 < len(bitmap) {
+				bottom = bitmap[y+1][x]
+			}
+
+			fg, bg := ansiFgLight, ansiBgLight
+			if top {
+				fg = ansiFgDark
+			}
+			if bottom {
+				bg = ansiBgDark
+			}
+			if fg+bg != last {
+				bw.WriteString(fg + bg)
+				last = fg + bg
+			}
+			bw.WriteString(upperHalfBlock)
+		}
+		bw.WriteString(ansiReset + "\n")
+	}
+
+	return bw.Flush()
+}
+
+// PNG returns the code as a PNG image of size by size pixels.
+func (c *Code) PNG(size int) ([]byte, error) {
+	return c.qr.PNG(size)
+}
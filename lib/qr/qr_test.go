@@ -0,0 +1,42 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestANSI(t *testing.T) {
+	code, err := New([]byte("https://vpn.example.org/download/3f1c"))
+	assert.NoError(t, err)
+
+	bitmap := code.Bitmap()
+	assert.True(t, len(bitmap) > 21)
+
+	var buf bytes.Buffer
+	assert.NoError(t, code.WriteANSI(&buf))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Equal(t, (len(bitmap)+1)/2, len(lines))
+	assert.Equal(t, len(bitmap[0]), strings.Count(lines[0], upperHalfBlock))
+}
+
+func TestPNG(t *testing.T) {
+	code, err := New([]byte("client\nremote vpn.example.org\n"))
+	assert.NoError(t, err)
+
+	buf, err := code.PNG(256)
+	assert.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(buf))
+	assert.NoError(t, err)
+	assert.Equal(t, 256, img.Bounds().Dx())
+}
+
+func TestTooLarge(t *testing.T) {
+	_, err := New(bytes.Repeat([]byte("x"), 4096))
+	assert.Equal(t, ErrTooLarge, err)
+}
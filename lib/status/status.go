@@ -0,0 +1,323 @@
+// Package status parses the status files written by OpenVPN servers (see the
+// status and status-version directives).
+package status
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client is a connected client.
+type Client struct {
+	CommonName         string    `json:"common_name"`
+	RealAddress        string    `json:"real_address"`
+	VirtualAddress     string    `json:"virtual_address,omitempty"`
+	VirtualIPv6Address string    `json:"virtual_ipv6_address,omitempty"`
+	BytesReceived      uint64    `json:"bytes_received"`
+	BytesSent          uint64    `json:"bytes_sent"`
+	ConnectedSince     time.Time `json:"connected_since"`
+	Username           string    `json:"username,omitempty"`
+	ClientID           string    `json:"client_id,omitempty"`
+	PeerID             string    `json:"peer_id,omitempty"`
+	Cipher             string    `json:"cipher,omitempty"`
+}
+
+// Route is an entry of the server's routing table.
+type Route struct {
+	VirtualAddress string    `json:"virtual_address"`
+	CommonName     string    `json:"common_name"`
+	RealAddress    string    `json:"real_address"`
+	LastRef        time.Time `json:"last_ref"`
+}
+
+// Status is the parsed content of a status file.
+type Status struct {
+	Version     int               `json:"version"`
+	Title       string            `json:"title,omitempty"`
+	Updated     time.Time         `json:"updated"`
+	Clients     []Client          `json:"clients"`
+	Routes      []Route           `json:"routes"`
+	GlobalStats map[string]string `json:"global_stats,omitempty"`
+}
+
+// Client returns the connected client with the given common name.
+func (s *Status) Client(commonName string) (*Client, bool) {
+	for i := range s.Clients {
+		if s.Clients[i].CommonName == commonName {
+			return &s.Clients[i], true
+		}
+	}
+	return nil, false
+}
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"Mon Jan _2 15:04:05 2006",
+}
+
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+func parseUnix(value string) (time.Time, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+	}
+	return time.Unix(n, 0), nil
+}
+
+// Parse reads a status file written with status-version 1, 2 or 3, or the
+// output of the management interface's "status" command.
+func Parse(r io.Reader) (*Status, error) {
+	lines := []string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 {
+		return nil, errors.New("empty status file")
+	}
+
+	switch {
+	case lines[0] == "OpenVPN CLIENT LIST":
+		return parseV1(lines)
+	case strings.HasPrefix(lines[0], "TITLE,"):
+		return parseV2(lines, ",", 2)
+	case strings.HasPrefix(lines[0], "TITLE\t"):
+		return parseV2(lines, "\t", 3)
+	}
+
+	return nil, errors.New("unknown status file format")
+}
+
+// record gives access to the fields of a row by column name.
+type record map[string]string
+
+func newRecord(header []string, fields []string) record {
+	rec := record{}
+	for i := range header {
+		if i < len(fields) {
+			rec[header[i]] = fields[i]
+		}
+	}
+	return rec
+}
+
+func (rec record) uint(name string) (uint64, error) {
+	value, ok := rec[name]
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return n, nil
+}
+
+func (rec record) string(name string) string {
+	if value := rec[name]; value != "UNDEF" {
+		return value
+	}
+	return ""
+}
+
+func (rec record) client() (Client, error) {
+	client := Client{
+		CommonName:         rec.string("Common Name"),
+		RealAddress:        rec.string("Real Address"),
+		VirtualAddress:     rec.string("Virtual Address"),
+		VirtualIPv6Address: rec.string("Virtual IPv6 Address"),
+		Username:           rec.string("Username"),
+		ClientID:           rec.string("Client ID"),
+		PeerID:             rec.string("Peer ID"),
+		Cipher:             rec.string("Data Channel Cipher"),
+	}
+
+	var err error
+	if client.BytesReceived, err = rec.uint("Bytes Received"); err != nil {
+		return client, err
+	}
+	if client.BytesSent, err = rec.uint("Bytes Sent"); err != nil {
+		return client, err
+	}
+
+	if value, ok := rec["Connected Since (time_t)"]; ok {
+		client.ConnectedSince, err = parseUnix(value)
+	} else {
+		client.ConnectedSince, err = parseTime(rec["Connected Since"])
+	}
+
+	return client, err
+}
+
+func (rec record) route() (Route, error) {
+	route := Route{
+		VirtualAddress: rec.string("Virtual Address"),
+		CommonName:     rec.string("Common Name"),
+		RealAddress:    rec.string("Real Address"),
+	}
+
+	var err error
+	if value, ok := rec["Last Ref (time_t)"]; ok {
+		route.LastRef, err = parseUnix(value)
+	} else {
+		route.LastRef, err = parseTime(rec["Last Ref"])
+	}
+
+	return route, err
+}
+
+func parseV1(lines []string) (*Status, error) {
+	s := &Status{
+		Version:     1,
+		Clients:     []Client{},
+		Routes:      []Route{},
+		GlobalStats: map[string]string{},
+	}
+
+	var (
+		section string
+		header  []string
+	)
+
+	for i, line := range lines[1:] {
+		switch line {
+		case "ROUTING TABLE", "GLOBAL STATS":
+			section, header = line, nil
+			continue
+		case "END":
+			return s.joinRoutes(), nil
+		}
+
+		fields := strings.Split(line, ",")
+
+		if section == "" && fields[0] == "Updated" && len(fields) > 1 {
+			var err error
+			if s.Updated, err = parseTime(strings.Join(fields[1:], ",")); err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+2, err)
+			}
+			section = "CLIENT LIST"
+			continue
+		}
+
+		if section == "GLOBAL STATS" {
+			if len(fields) > 1 {
+				s.GlobalStats[fields[0]] = strings.Join(fields[1:], ",")
+			}
+			continue
+		}
+
+		if header == nil {
+			header = fields
+			continue
+		}
+
+		rec := newRecord(header, fields)
+
+		switch section {
+		case "CLIENT LIST":
+			client, err := rec.client()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+2, err)
+			}
+			s.Clients = append(s.Clients, client)
+		case "ROUTING TABLE":
+			route, err := rec.route()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+2, err)
+			}
+			s.Routes = append(s.Routes, route)
+		}
+	}
+
+	return nil, errors.New("unexpected end of status file")
+}
+
+func parseV2(lines []string, sep string, version int) (*Status, error) {
+	s := &Status{
+		Version:     version,
+		Clients:     []Client{},
+		Routes:      []Route{},
+		GlobalStats: map[string]string{},
+	}
+
+	headers := map[string][]string{}
+
+	for i, line := range lines {
+		fields := strings.Split(line, sep)
+
+		var err error
+
+		switch fields[0] {
+		case "TITLE":
+			s.Title = strings.Join(fields[1:], sep)
+		case "TIME":
+			if len(fields) > 2 {
+				s.Updated, err = parseUnix(fields[2])
+			}
+		case "HEADER":
+			if len(fields) > 2 {
+				headers[fields[1]] = fields[2:]
+			}
+		case "CLIENT_LIST":
+			var client Client
+			if client, err = newRecord(headers["CLIENT_LIST"], fields[1:]).client(); err == nil {
+				s.Clients = append(s.Clients, client)
+			}
+		case "ROUTING_TABLE":
+			var route Route
+			if route, err = newRecord(headers["ROUTING_TABLE"], fields[1:]).route(); err == nil {
+				s.Routes = append(s.Routes, route)
+			}
+		case "GLOBAL_STATS":
+			if len(fields) > 2 {
+				s.GlobalStats[fields[1]] = strings.Join(fields[2:], sep)
+			}
+		case "END":
+			return s.joinRoutes(), nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+	}
+
+	return nil, errors.New("unexpected end of status file")
+}
+
+// joinRoutes fills in the virtual address of clients from the routing table,
+// status-version 1 files don't include it in the client list.
+func (s *Status) joinRoutes() *Status {
+	for i := range s.Clients {
+		if s.Clients[i].VirtualAddress != "" {
+			continue
+		}
+		for _, route := range s.Routes {
+			if route.CommonName == s.Clients[i].CommonName && route.RealAddress == s.Clients[i].RealAddress && !strings.Contains(route.VirtualAddress, "/") {
+				s.Clients[i].VirtualAddress = route.VirtualAddress
+				break
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+package status
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const statusV1 = `OpenVPN CLIENT LIST
+Updated,2021-01-01 00:10:00
+Common Name,Real Address,Bytes Received,Bytes Sent,Connected Since
+laptop,192.0.2.1:49502,334948,1973012,2021-01-01 00:00:00
+phone,198.51.100.7:1194,1024,2048,Fri Jan  1 00:05:00 2021
+ROUTING TABLE
+Virtual Address,Common Name,Real Address,Last Ref
+10.9.0.2,laptop,192.0.2.1:49502,2021-01-01 00:09:00
+10.9.0.3,phone,198.51.100.7:1194,2021-01-01 00:09:30
+GLOBAL STATS
+Max bcast/mcast queue length,0
+END
+`
+
+const statusV2 = `TITLE,OpenVPN 2.5.1 x86_64-pc-linux-gnu
+TIME,2021-01-01 00:10:00,1609459800
+HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Virtual IPv6 Address,Bytes Received,Bytes Sent,Connected Since,Connected Since (time_t),Username,Client ID,Peer ID,Data Channel Cipher
+CLIENT_LIST,laptop,192.0.2.1:49502,10.9.0.2,,334948,1973012,2021-01-01 00:00:00,1609459200,UNDEF,0,0,AES-256-GCM
+HEADER,ROUTING_TABLE,Virtual Address,Common Name,Real Address,Last Ref,Last Ref (time_t)
+ROUTING_TABLE,10.9.0.2,laptop,192.0.2.1:49502,2021-01-01 00:09:00,1609459740
+GLOBAL_STATS,Max bcast/mcast queue length,0
+END
+`
+
+func TestParseV1(t *testing.T) {
+	s, err := Parse(strings.NewReader(statusV1))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, s.Version)
+	assert.Equal(t, 2, len(s.Clients))
+	assert.Equal(t, 2, len(s.Routes))
+	assert.Equal(t, "0", s.GlobalStats["Max bcast/mcast queue length"])
+
+	laptop, ok := s.Client("laptop")
+	assert.True(t, ok)
+	assert.Equal(t, "10.9.0.2", laptop.VirtualAddress)
+	assert.Equal(t, uint64(334948), laptop.BytesReceived)
+	assert.Equal(t, uint64(1973012), laptop.BytesSent)
+	assert.Equal(t, time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local), laptop.ConnectedSince)
+
+	phone, ok := s.Client("phone")
+	assert.True(t, ok)
+	assert.Equal(t, "10.9.0.3", phone.VirtualAddress)
+	assert.Equal(t, time.Date(2021, 1, 1, 0, 5, 0, 0, time.Local), phone.ConnectedSince)
+}
+
+func TestParseV2V3(t *testing.T) {
+	for version, input := range map[int]string{
+		2: statusV2,
+		3: strings.Replace(statusV2, ",", "\t", -1),
+	} {
+		s, err := Parse(strings.NewReader(input))
+		assert.NoError(t, err)
+
+		assert.Equal(t, version, s.Version)
+		assert.Equal(t, "OpenVPN 2.5.1 x86_64-pc-linux-gnu", s.Title)
+		assert.Equal(t, int64(1609459800), s.Updated.Unix())
+		assert.Equal(t, 1, len(s.Clients))
+		assert.Equal(t, 1, len(s.Routes))
+
+		laptop := s.Clients[0]
+		assert.Equal(t, "laptop", laptop.CommonName)
+		assert.Equal(t, "10.9.0.2", laptop.VirtualAddress)
+		assert.Equal(t, "", laptop.Username)
+		assert.Equal(t, "AES-256-GCM", laptop.Cipher)
+		assert.Equal(t, int64(1609459200), laptop.ConnectedSince.Unix())
+		assert.Equal(t, int64(1609459740), s.Routes[0].LastRef.Unix())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse(strings.NewReader(""))
+	assert.Error(t, err)
+
+	_, err = Parse(strings.NewReader("garbage\n"))
+	assert.Error(t, err)
+
+	_, err = Parse(strings.NewReader(strings.Replace(statusV2, "END\n", "", 1)))
+	assert.Error(t, err)
+
+	_, err = Parse(strings.NewReader(strings.Replace(statusV2, "334948", "lots", 1)))
+	assert.Error(t, err)
+}
@@ -0,0 +1,250 @@
+// Package totp implements the time-based one-time passwords of RFC 6238, the
+// six digit codes authenticator apps show, and the static-challenge
+// responses OpenVPN clients send them in.
+package totp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parameters every authenticator app supports.
+const (
+	Digits = 6
+	Period = 30 * time.Second
+
+	secretLen = 20
+)
+
+// Skew is the number of periods a code is accepted before and after the
+// current one, to allow for clock drift.
+const Skew = 1
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random secret, base32 encoded.
+func GenerateSecret() (string, error) {
+	buf := make([]byte, secretLen)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(buf), nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	key, err := encoding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("invalid TOTP secret")
+	}
+	return key, nil
+}
+
+// hotp returns the code of counter, as in RFC 4226.
+func hotp(key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+func counter(t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(Period/time.Second)
+}
+
+// Code returns the code of secret at t.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, counter(t), Digits), nil
+}
+
+// Validate reports whether code is the code of secret at t, give or take
+// Skew periods.
+func Validate(secret string, code string, t time.Time) (bool, error) {
+	_, ok, err := Match(secret, code, t)
+	return ok, err
+}
+
+// Match is like Validate, it also returns the counter of the period code
+// belongs to, to be recorded in a State.
+func Match(secret string, code string, t time.Time) (uint64, bool, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, false, nil
+	}
+
+	now := counter(t)
+	var matched uint64
+	valid := 0
+	for i := -Skew; i <= Skew; i++ {
+		expected := hotp(key, now+uint64(i), Digits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			matched, valid = now+uint64(i), 1
+		}
+	}
+
+	return matched, valid == 1, nil
+}
+
+// State holds the counter of the last code accepted from every user. Codes
+// stay valid for Skew periods, a State rejects them once they were used, or
+// once a later code was.
+//
+// Every line of its file holds a user name and a counter separated by a
+// colon.
+type State struct {
+	counters map[string]uint64
+}
+
+// ReadState parses a State file.
+func ReadState(r io.Reader) (*State, error) {
+	s := &State{counters: map[string]uint64{}}
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		i := strings.LastIndex(line, ":")
+		if i < 1 {
+			return nil, fmt.Errorf("line %d: expecting user:counter", lineNo)
+		}
+		n, err := strconv.ParseUint(line[i+1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid counter %q", lineNo, line[i+1:])
+		}
+		s.counters[line[:i]] = n
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// LoadState reads the State file name, a missing file holds no counters.
+func LoadState(name string) (*State, error) {
+	fp, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &State{counters: map[string]uint64{}}, nil
+		}
+		return nil, err
+	}
+	defer fp.Close()
+
+	return ReadState(fp)
+}
+
+// Accept records counter as the last one of user, it reports false if
+// counter is not past the last one recorded.
+func (s *State) Accept(user string, counter uint64) bool {
+	if last, ok := s.counters[user]; ok && counter <= last {
+		return false
+	}
+	s.counters[user] = counter
+	return true
+}
+
+// Bytes returns the contents of the State file.
+func (s *State) Bytes() []byte {
+	users := make([]string, 0, len(s.counters))
+	for user := range s.counters {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+
+	var buf bytes.Buffer
+	for _, user := range users {
+		fmt.Fprintf(&buf, "%s:%d\n", user, s.counters[user])
+	}
+	return buf.Bytes()
+}
+
+// URL returns the otpauth:// URL authenticator apps enroll from, usually
+// scanned as a QR code.
+func URL(issuer string, account string, secret string) string {
+	u := url.URL{
+		Scheme: "otpauth",
+		Host:   "totp",
+		Path:   "/" + issuer + ":" + account,
+	}
+
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(int(Period/time.Second)))
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// ErrNoResponse is returned by SplitResponse when the password holds no
+// code.
+var ErrNoResponse = errors.New("missing one-time code")
+
+// SplitResponse separates the password from the code sent with it. Clients
+// with static-challenge send "SCRV1:base64(password):base64(code)", other
+// clients can append the code to the password.
+func SplitResponse(password string) (string, string, error) {
+	if strings.HasPrefix(password, "SCRV1:") {
+		parts := strings.SplitN(password, ":", 3)
+		if len(parts) != 3 {
+			return "", "", errors.New("malformed static-challenge response")
+		}
+		pass, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return "", "", errors.New("malformed static-challenge password")
+		}
+		code, err := base64.StdEncoding.DecodeString(parts[2])
+		if err != nil {
+			return "", "", errors.New("malformed static-challenge response")
+		}
+		return string(pass), strings.TrimSpace(string(code)), nil
+	}
+
+	if len(password) <= Digits {
+		return "", "", ErrNoResponse
+	}
+	return password[:len(password)-Digits], password[len(password)-Digits:], nil
+}
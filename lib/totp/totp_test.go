@@ -0,0 +1,125 @@
+package totp
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The SHA-1 secret of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	for _, v := range []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	} {
+		key, err := decodeSecret(rfcSecret)
+		assert.NoError(t, err)
+		assert.Equal(t, v.code, hotp(key, counter(time.Unix(v.unix, 0)), 8))
+
+		code, err := Code(rfcSecret, time.Unix(v.unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, v.code[2:], code)
+	}
+
+	_, err := Code("not base32!", time.Now())
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+	assert.Equal(t, 32, len(secret))
+
+	now := time.Unix(1700000000, 0)
+	code, err := Code(secret, now)
+	assert.NoError(t, err)
+
+	for _, offset := range []time.Duration{0, -Period, Period} {
+		ok, err := Validate(secret, code, now.Add(offset))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+
+	for _, offset := range []time.Duration{-2 * Period, 2 * Period} {
+		ok, err := Validate(secret, code, now.Add(offset))
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+
+	ok, _ := Validate(secret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestState(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	code, err := Code(secret, now)
+	assert.NoError(t, err)
+
+	counter, ok, err := Match(secret, code, now.Add(Period))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1700000000/30), counter)
+
+	state, err := ReadState(strings.NewReader("bob:10\n"))
+	assert.NoError(t, err)
+
+	assert.True(t, state.Accept("alice", counter))
+	assert.False(t, state.Accept("alice", counter))
+	assert.False(t, state.Accept("alice", counter-1))
+	assert.True(t, state.Accept("alice", counter+1))
+	assert.False(t, state.Accept("bob", 10))
+
+	assert.Equal(t, fmt.Sprintf("alice:%d\nbob:10\n", counter+1), string(state.Bytes()))
+
+	_, err = ReadState(strings.NewReader("alice\n"))
+	assert.Error(t, err)
+}
+
+func TestURL(t *testing.T) {
+	u, err := url.Parse(URL("Example VPN", "alice", "JBSWY3DPEHPK3PXP"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Example VPN:alice", u.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", u.Query().Get("secret"))
+	assert.Equal(t, "Example VPN", u.Query().Get("issuer"))
+}
+
+func TestSplitResponse(t *testing.T) {
+	enc := base64.StdEncoding.EncodeToString
+
+	password, code, err := SplitResponse("SCRV1:" + enc([]byte("hunter2")) + ":" + enc([]byte("123456")))
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", password)
+	assert.Equal(t, "123456", code)
+
+	password, code, err = SplitResponse("hunter2123456")
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", password)
+	assert.Equal(t, "123456", code)
+
+	_, _, err = SplitResponse("12345")
+	assert.Equal(t, ErrNoResponse, err)
+
+	_, _, err = SplitResponse("SCRV1:nope")
+	assert.Error(t, err)
+}
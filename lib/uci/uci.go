@@ -0,0 +1,157 @@
+// Package uci converts OpenVPN configurations into the UCI syntax OpenWrt
+// uses to configure OpenVPN (/etc/config/openvpn).
+//
+// OpenWrt's init script turns every option of an openvpn section into a
+// directive, replacing underscores with dashes. Arguments with spaces are
+// quoted the way OpenVPN reads them. Inline blocks are moved to
+// their own files, which the section references by absolute path.
+package uci
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/xiam/openvpn-config-generator/lib/archive"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+// ConfigName is the name of the UCI configuration file of OpenVPN.
+const ConfigName = "openvpn"
+
+// Options of a section.
+type Options struct {
+	// Name of the section, it's also used to name the extracted files.
+	Name string
+
+	// Dir is the absolute path the extracted files are installed to.
+	Dir string
+
+	// Disabled creates the section with enabled set to 0.
+	Disabled bool
+}
+
+// File is a certificate or key referenced by the section.
+type File = generator.File
+
+// Section is an openvpn section and the files it references.
+type Section struct {
+	Config []byte
+	Files  []File
+}
+
+// lists are directives that may be given more than once, OpenWrt expects
+// them as lists even if there's only one.
+var lists = map[string]bool{
+	"remote":     true,
+	"push":       true,
+	"route":      true,
+	"route-ipv6": true,
+	"setenv":     true,
+}
+
+// defaults holds the value of directives that take an optional argument,
+// OpenWrt doesn't accept them without one.
+var defaults = map[string]string{
+	"comp-lzo": "adaptive",
+}
+
+var invalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// SectionName returns name with the characters UCI doesn't allow in section
+// names replaced by underscores.
+func SectionName(name string) string {
+	return invalidChars.ReplaceAllString(name, "_")
+}
+
+// Convert returns the openvpn section for a server or client configuration.
+func Convert(config *generator.Config, opts Options) (*Section, error) {
+	if opts.Name == "" {
+		return nil, errors.New("missing section name")
+	}
+	if !path.IsAbs(opts.Dir) {
+		return nil, fmt.Errorf("%q is not an absolute path", opts.Dir)
+	}
+
+	profile, files, err := config.Split(func(directive string) string {
+		return path.Join(opts.Dir, archive.FileName(directive, opts.Name))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range files {
+		files[i].Name = path.Base(files[i].Name)
+	}
+
+	// There's no nobody group on OpenWrt.
+	if group, ok := profile.Get("group"); ok && len(group) > 0 && group[0] == "nobody" {
+		profile.MustSet("group", "nogroup")
+	}
+
+	var buf bytes.Buffer
+
+	enabled := "1"
+	if opts.Disabled {
+		enabled = "0"
+	}
+
+	fmt.Fprintf(&buf, "config openvpn %s\n", quote(SectionName(opts.Name)))
+	fmt.Fprintf(&buf, "\toption enabled %s\n", quote(enabled))
+
+	seen := map[string]bool{}
+	for _, name := range profile.Names() {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		option := strings.Replace(name, "-", "_", -1)
+
+		all := profile.GetAll(name)
+		for _, args := range all {
+			value := joinArgs(name, args)
+			if len(args) == 0 {
+				value = "1"
+				if v, ok := defaults[name]; ok {
+					value = v
+				}
+			}
+
+			kind := "option"
+			if lists[name] || len(all) > 1 {
+				kind = "list"
+			}
+			fmt.Fprintf(&buf, "\t%s %s %s\n", kind, option, quote(value))
+		}
+	}
+
+	return &Section{Config: buf.Bytes(), Files: files}, nil
+}
+
+// joinArgs joins the arguments of a directive into an option value. The
+// init script writes the value after the directive as is, so arguments
+// holding spaces keep their OpenVPN quotes. push is the exception, the
+// script quotes it itself.
+func joinArgs(name string, args []string) string {
+	if name == "push" {
+		return strings.Join(args, " ")
+	}
+
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"\\") || strings.ContainsAny(arg[:1], "'#;") {
+			arg = generator.Quote(arg)
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// quote wraps s in single quotes, the way uci export does.
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,280 @@
+// Package verify checks that the certificates and keys bundled in an OpenVPN
+// configuration belong together.
+package verify
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xiam/openvpn-config-generator/lib/certtool"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+// Role is the side of the connection a configuration is meant for.
+type Role string
+
+const (
+	RoleServer Role = "server"
+	RoleClient Role = "client"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Check is the result of a single verification step.
+type Check struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// Report holds the result of every check performed on a configuration.
+type Report struct {
+	Role   Role    `json:"role"`
+	Checks []Check `json:"checks"`
+}
+
+// OK reports whether no check failed.
+func (r *Report) OK() bool {
+	for i := range r.Checks {
+		if r.Checks[i].Status == StatusFail {
+			return false
+		}
+	}
+	return true
+}
+
+// Verified reports whether the certificate was checked, it's not when it
+// is in a password protected PKCS#12 bundle.
+func (r *Report) Verified() bool {
+	for i := range r.Checks {
+		if r.Checks[i].Name == "cert" && r.Checks[i].Status == StatusPass {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) add(name string, status Status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, Check{
+		Name:    name,
+		Status:  status,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Options tune Config.
+type Options struct {
+	// Role overrides the role detected from the configuration.
+	Role Role
+
+	// Now is the point in time validity is checked against, the current
+	// time is used if zero.
+	Now time.Time
+
+	// CRL is a PEM or DER encoded revocation list, it takes precedence over
+	// an inline <crl-verify> block.
+	CRL []byte
+}
+
+// DetectRole guesses whether cfg is a client or a server configuration.
+func DetectRole(cfg *generator.Config) (Role, error) {
+	if cfg.Has("client") || cfg.Has("tls-client") {
+		return RoleClient, nil
+	}
+	if cfg.Has("server") || cfg.Has("tls-server") {
+		return RoleServer, nil
+	}
+	if mode, ok := cfg.Get("mode"); ok && len(mode) > 0 && mode[0] == "server" {
+		return RoleServer, nil
+	}
+	return "", errors.New("could not tell whether this is a client or a server configuration")
+}
+
+// Config verifies the <ca>, <cert> and <key> blocks of cfg: the certificate
+// must be signed by the CA, match the private key, carry the extended key
+// usage for its role, be within its validity window and not be revoked.
+func Config(cfg *generator.Config, opts *Options) (*Report, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	role := opts.Role
+	if role == "" {
+		var err error
+		if role, err = DetectRole(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &Report{Role: role, Checks: []Check{}}
+
+	caBuf, ok := cfg.Embedded("ca")
+	if !ok {
+		report.add("ca", StatusFail, "missing <ca> block")
+		return report, nil
+	}
+	cas, err := certtool.ParseCertificatesPEM(caBuf)
+	if err != nil {
+		report.add("ca", StatusFail, "could not parse <ca>: %v", err)
+		return report, nil
+	}
+	report.add("ca", StatusPass, "found %d CA certificate(s)", len(cas))
+
+	certBuf, ok := cfg.Embedded("cert")
+	if !ok && cfg.Has("pkcs12") {
+		report.add("cert", StatusSkip, "certificate and key are in a password protected PKCS#12 bundle")
+		return report, nil
+	}
+	if !ok {
+		report.add("cert", StatusFail, "missing <cert> block")
+		return report, nil
+	}
+	certs, err := certtool.ParseCertificatesPEM(certBuf)
+	if err != nil {
+		report.add("cert", StatusFail, "could not parse <cert>: %v", err)
+		return report, nil
+	}
+	cert := certs[0]
+	report.add("cert", StatusPass, "found certificate for %q", cert.Subject.CommonName)
+
+	checkChain(report, cert, cas)
+	checkKey(report, cfg, cert)
+	checkUsage(report, role, cert)
+	checkValidity(report, now, cert, cas)
+
+	crlBuf := opts.CRL
+	if crlBuf == nil {
+		crlBuf, _ = cfg.Embedded("crl-verify")
+	}
+	checkRevocation(report, now, crlBuf, cert, cas)
+
+	return report, nil
+}
+
+func checkChain(report *Report, cert *x509.Certificate, cas []*x509.Certificate) {
+	for _, ca := range cas {
+		if err := cert.CheckSignatureFrom(ca); err == nil {
+			report.add("chain", StatusPass, "certificate is signed by %q", ca.Subject.CommonName)
+			return
+		}
+	}
+	report.add("chain", StatusFail, "certificate issued by %q is not signed by any of the bundled CAs", cert.Issuer.CommonName)
+}
+
+func checkKey(report *Report, cfg *generator.Config, cert *x509.Certificate) {
+	keyBuf, ok := cfg.Embedded("key")
+	if !ok {
+		report.add("key", StatusFail, "missing <key> block")
+		return
+	}
+
+	block, _ := pem.Decode(keyBuf)
+	if block == nil {
+		report.add("key", StatusFail, "could not decode <key>")
+		return
+	}
+
+	key, err := certtool.ParsePrivateKey(block.Bytes)
+	if err != nil {
+		report.add("key", StatusFail, "could not parse <key>: %v", err)
+		return
+	}
+
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(cert.PublicKey) {
+		report.add("key", StatusFail, "private key does not match the certificate")
+		return
+	}
+	report.add("key", StatusPass, "private key matches the certificate")
+}
+
+func checkUsage(report *Report, role Role, cert *x509.Certificate) {
+	want := x509.ExtKeyUsageClientAuth
+	if role == RoleServer {
+		want = x509.ExtKeyUsageServerAuth
+	}
+
+	for _, eku := range cert.ExtKeyUsage {
+		if eku == want || eku == x509.ExtKeyUsageAny {
+			report.add("eku", StatusPass, "certificate can be used by a %s", role)
+			return
+		}
+	}
+	report.add("eku", StatusFail, "certificate lacks the extended key usage required by a %s", role)
+}
+
+func checkValidity(report *Report, now time.Time, cert *x509.Certificate, cas []*x509.Certificate) {
+	failed := false
+	for _, c := range append([]*x509.Certificate{cert}, cas...) {
+		switch {
+		case now.Before(c.NotBefore):
+			report.add("validity", StatusFail, "%q is not valid before %s", c.Subject.CommonName, c.NotBefore.Format(time.RFC3339))
+			failed = true
+		case now.After(c.NotAfter):
+			report.add("validity", StatusFail, "%q expired on %s", c.Subject.CommonName, c.NotAfter.Format(time.RFC3339))
+			failed = true
+		}
+	}
+	if !failed {
+		report.add("validity", StatusPass, "certificate is valid until %s", cert.NotAfter.Format(time.RFC3339))
+	}
+}
+
+func checkRevocation(report *Report, now time.Time, buf []byte, cert *x509.Certificate, cas []*x509.Certificate) {
+	if len(buf) == 0 {
+		report.add("crl", StatusSkip, "no CRL available")
+		return
+	}
+
+	der := buf
+	if block, _ := pem.Decode(buf); block != nil {
+		der = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		report.add("crl", StatusFail, "could not parse CRL: %v", err)
+		return
+	}
+
+	signed := false
+	for _, ca := range cas {
+		if crl.CheckSignatureFrom(ca) == nil {
+			signed = true
+			break
+		}
+	}
+	if !signed {
+		report.add("crl", StatusFail, "CRL is not signed by any of the bundled CAs")
+		return
+	}
+
+	if !crl.NextUpdate.IsZero() && now.After(crl.NextUpdate) {
+		report.add("crl", StatusFail, "CRL is outdated since %s", crl.NextUpdate.Format(time.RFC3339))
+		return
+	}
+
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			report.add("crl", StatusFail, "certificate was revoked on %s", entry.RevocationTime.Format(time.RFC3339))
+			return
+		}
+	}
+	report.add("crl", StatusPass, "certificate is not revoked")
+}
@@ -0,0 +1,143 @@
+package verify
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	ovpncfg "github.com/xiam/openvpn-config-generator"
+	"github.com/xiam/openvpn-config-generator/lib/certtool"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+)
+
+func clientConfig(t *testing.T, caCert, cert, key []byte) *generator.Config {
+	config, err := ovpncfg.NewClientConfig()
+	assert.NoError(t, err)
+
+	config.MustEmbed("ca", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert}))
+	config.MustEmbed("cert", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}))
+	config.MustEmbed("key", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}))
+
+	return config
+}
+
+func checkStatus(report *Report, name string) Status {
+	for _, check := range report.Checks {
+		if check.Name == name && check.Status == StatusFail {
+			return StatusFail
+		}
+	}
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check.Status
+		}
+	}
+	return ""
+}
+
+func TestConfig(t *testing.T) {
+	caCert, caKey, err := certtool.BuildCA()
+	assert.NoError(t, err)
+
+	otherCACert, otherCAKey, err := certtool.BuildCA()
+	assert.NoError(t, err)
+
+	clientCert, clientKey, err := certtool.BuildClientCertificate(caCert, caKey, "client.local")
+	assert.NoError(t, err)
+
+	serverCert, serverKey, err := certtool.BuildServerCertificate(caCert, caKey, "server.tld")
+	assert.NoError(t, err)
+
+	otherCert, _, err := certtool.BuildClientCertificate(otherCACert, otherCAKey, "other.local")
+	assert.NoError(t, err)
+
+	{
+		report, err := Config(clientConfig(t, caCert, clientCert, clientKey), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, RoleClient, report.Role)
+		assert.True(t, report.OK())
+		assert.True(t, report.Verified())
+		assert.Equal(t, StatusSkip, checkStatus(report, "crl"))
+	}
+
+	{
+		report, err := Config(clientConfig(t, otherCACert, clientCert, clientKey), nil)
+		assert.NoError(t, err)
+		assert.False(t, report.OK())
+		assert.Equal(t, StatusFail, checkStatus(report, "chain"))
+	}
+
+	{
+		report, err := Config(clientConfig(t, caCert, otherCert, clientKey), nil)
+		assert.NoError(t, err)
+		assert.False(t, report.OK())
+		assert.Equal(t, StatusFail, checkStatus(report, "key"))
+	}
+
+	{
+		report, err := Config(clientConfig(t, caCert, serverCert, serverKey), nil)
+		assert.NoError(t, err)
+		assert.False(t, report.OK())
+		assert.Equal(t, StatusFail, checkStatus(report, "eku"))
+	}
+
+	{
+		report, err := Config(clientConfig(t, caCert, clientCert, clientKey), &Options{Now: time.Now().AddDate(20, 0, 0)})
+		assert.NoError(t, err)
+		assert.False(t, report.OK())
+		assert.Equal(t, StatusFail, checkStatus(report, "validity"))
+	}
+}
+
+func TestRevocation(t *testing.T) {
+	caCert, caKey, err := certtool.BuildCA()
+	assert.NoError(t, err)
+
+	clientCert, clientKey, err := certtool.BuildClientCertificate(caCert, caKey, "client.local")
+	assert.NoError(t, err)
+
+	ca, err := x509.ParseCertificate(caCert)
+	assert.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(clientCert)
+	assert.NoError(t, err)
+
+	signer, err := certtool.ParsePrivateKey(caKey)
+	assert.NoError(t, err)
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: cert.SerialNumber, RevocationTime: time.Now()},
+		},
+	}, ca, signer)
+	assert.NoError(t, err)
+
+	report, err := Config(clientConfig(t, caCert, clientCert, clientKey), &Options{CRL: crl})
+	assert.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, StatusFail, checkStatus(report, "crl"))
+}
+
+func TestPKCS12(t *testing.T) {
+	caCert, _, err := certtool.BuildCA()
+	assert.NoError(t, err)
+
+	config, err := ovpncfg.NewClientConfig()
+	assert.NoError(t, err)
+
+	config.MustEmbed("ca", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert}))
+	config.MustEmbed("pkcs12", []byte("MIIS"))
+
+	report, err := Config(config, nil)
+	assert.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.False(t, report.Verified())
+	assert.Equal(t, StatusSkip, checkStatus(report, "cert"))
+}
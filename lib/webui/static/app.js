@@ -0,0 +1,141 @@
+(function () {
+  'use strict';
+
+  var $ = function (id) { return document.getElementById(id); };
+
+  function token() {
+    return sessionStorage.getItem('token');
+  }
+
+  function showError(message) {
+    $('error').textContent = message;
+    $('error').hidden = !message;
+  }
+
+  function request(method, path, body) {
+    var opts = {
+      method: method,
+      headers: { 'Authorization': 'Bearer ' + token() }
+    };
+    if (body !== undefined) {
+      opts.headers['Content-Type'] = 'application/json';
+      opts.body = JSON.stringify(body);
+    }
+    return fetch('api/' + path, opts).then(function (res) {
+      return res.json().then(function (data) {
+        if (res.status === 401) {
+          logout();
+        }
+        if (!res.ok) {
+          throw new Error(data.error || res.statusText);
+        }
+        return data;
+      });
+    });
+  }
+
+  function button(label, fn) {
+    var b = document.createElement('button');
+    b.type = 'button';
+    b.textContent = label;
+    b.addEventListener('click', fn);
+    return b;
+  }
+
+  function cell(text) {
+    var td = document.createElement('td');
+    td.textContent = text;
+    return td;
+  }
+
+  function render(entries) {
+    var list = $('list');
+    list.textContent = '';
+
+    entries.forEach(function (entry) {
+      var tr = document.createElement('tr');
+      tr.className = entry.status;
+      tr.appendChild(cell(entry.common_name));
+      tr.appendChild(cell(entry.status));
+      tr.appendChild(cell(new Date(entry.not_after).toLocaleDateString()));
+
+      var actions = document.createElement('td');
+      if (entry.status === 'valid') {
+        actions.appendChild(button('Download link', function () { createLink(entry.common_name); }));
+        actions.appendChild(button('Revoke', function () { revoke(entry.common_name); }));
+      }
+      tr.appendChild(actions);
+
+      list.appendChild(tr);
+    });
+  }
+
+  function refresh() {
+    return request('GET', 'clients').then(render).catch(function (err) {
+      showError(err.message);
+    });
+  }
+
+  function createLink(name) {
+    showError('');
+    request('POST', 'clients/' + encodeURIComponent(name) + '/link').then(function (data) {
+      var url = new URL(data.url, location.href).href;
+      $('link-url').textContent = url;
+      $('link-url').href = url;
+      $('link').hidden = false;
+    }).catch(function (err) {
+      showError(err.message);
+    });
+  }
+
+  function revoke(name) {
+    if (!confirm('Revoke ' + name + '? This cannot be undone.')) {
+      return;
+    }
+    showError('');
+    request('DELETE', 'clients/' + encodeURIComponent(name)).then(refresh).catch(function (err) {
+      showError(err.message);
+    });
+  }
+
+  function login() {
+    $('login').hidden = true;
+    $('clients').hidden = false;
+    refresh();
+  }
+
+  function logout() {
+    sessionStorage.removeItem('token');
+    $('login').hidden = false;
+    $('clients').hidden = true;
+    $('link').hidden = true;
+  }
+
+  $('login').addEventListener('submit', function (ev) {
+    ev.preventDefault();
+    sessionStorage.setItem('token', $('token').value);
+    $('token').value = '';
+    showError('');
+    login();
+  });
+
+  $('create').addEventListener('submit', function (ev) {
+    ev.preventDefault();
+    var name = $('name').value;
+    showError('');
+    request('POST', 'clients', { name: name }).then(function () {
+      $('name').value = '';
+      return refresh();
+    }).then(function () {
+      createLink(name);
+    }).catch(function (err) {
+      showError(err.message);
+    });
+  });
+
+  $('logout').addEventListener('click', logout);
+
+  if (token()) {
+    login();
+  }
+})();
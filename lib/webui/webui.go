@@ -0,0 +1,22 @@
+// Package webui provides a minimal web interface on top of the provisioning
+// API, it lists clients, creates one-time download links and revokes clients.
+package webui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler returns an http.Handler serving the web UI. The UI talks to the
+// API served under api.Prefix on the same host.
+func Handler() http.Handler {
+	root, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(root))
+}
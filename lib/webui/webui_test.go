@@ -0,0 +1,32 @@
+package webui
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	ts := httptest.NewServer(Handler())
+	defer ts.Close()
+
+	for _, path := range []string{"/", "/app.js", "/style.css"} {
+		res, err := http.Get(ts.URL + path)
+		assert.NoError(t, err)
+
+		buf, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode, path)
+		assert.NotEmpty(t, buf, path)
+	}
+
+	res, err := http.Get(ts.URL + "/missing.js")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
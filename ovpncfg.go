@@ -2,11 +2,14 @@ package ovpncfg
 
 import (
 	"crypto/rand"
-	"encoding/pem"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"strings"
 
 	"github.com/xiam/openvpn-config-generator/lib/generator"
+	"github.com/xiam/openvpn-config-generator/lib/output"
 )
 
 const (
@@ -94,6 +97,84 @@ func NewClientConfig() (*generator.Config, error) {
 	return config, nil
 }
 
+// ManagementAddress returns the network ("unix" or "tcp") and the address of
+// a management interface given as the path of a unix socket, optionally
+// prefixed by "unix:", or as a host:port pair.
+func ManagementAddress(address string) (string, string) {
+	if strings.HasPrefix(address, "unix:") || strings.Contains(address, "/") {
+		return "unix", strings.TrimPrefix(address, "unix:")
+	}
+	return "tcp", address
+}
+
+// SetManagement enables the management interface. address is either the path
+// of a unix socket (optionally prefixed by "unix:") or a host:port pair on the
+// loopback interface, which requires a password file.
+func SetManagement(config *generator.Config, address string, passwordFile string) error {
+	values := []interface{}{}
+
+	network, address := ManagementAddress(address)
+	if network == "unix" {
+		values = append(values, address, "unix")
+	} else {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("refusing to expose the management interface on %q, use a loopback address or a unix socket", host)
+		}
+
+		if passwordFile == "" {
+			return errors.New("a password file is required when listening on TCP")
+		}
+
+		values = append(values, host, port)
+	}
+
+	if passwordFile != "" {
+		values = append(values, passwordFile)
+	}
+
+	return config.Set("management", values...)
+}
+
+// ServerNetworks returns the networks clients get their addresses from,
+// given by the server and server-ipv6 directives.
+func ServerNetworks(config *generator.Config) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+
+	if values, ok := config.Get("server"); ok {
+		if len(values) < 2 {
+			return nil, errors.New("server: expecting a network and a netmask")
+		}
+		ip := net.ParseIP(values[0]).To4()
+		mask := net.ParseIP(values[1]).To4()
+		if ip == nil || mask == nil {
+			return nil, fmt.Errorf("server: invalid network %s %s", values[0], values[1])
+		}
+		networks = append(networks, &net.IPNet{IP: ip.Mask(net.IPMask(mask)), Mask: net.IPMask(mask)})
+	}
+
+	if values, ok := config.Get("server-ipv6"); ok {
+		if len(values) < 1 {
+			return nil, errors.New("server-ipv6: expecting a network")
+		}
+		_, network, err := net.ParseCIDR(values[0])
+		if err != nil {
+			return nil, fmt.Errorf("server-ipv6: %v", err)
+		}
+		networks = append(networks, network)
+	}
+
+	if len(networks) == 0 {
+		return nil, errors.New("missing server directive")
+	}
+
+	return networks, nil
+}
+
 func GenOpenVPNStaticKey() ([]byte, error) {
 	key := make([]byte, 256)
 	_, err := rand.Read(key)
@@ -117,40 +198,18 @@ func GenOpenVPNStaticKey() ([]byte, error) {
 	return buf, nil
 }
 
+// WriteConfig writes config to file using output.Default.
 func WriteConfig(config *generator.Config, file string) error {
-	buf, err := config.Compile()
-	if err != nil {
-		return err
-	}
-
-	return writeFile(buf, file)
+	return output.Default.WriteConfig(config, file)
 }
 
+// WriteCert writes a DER encoded certificate to file using output.Default.
 func WriteCert(cert []byte, file string) error {
-	return writeFile(pem.EncodeToMemory(&pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: cert,
-	}), file)
+	return output.Default.WriteCert(cert, file)
 }
 
+// WriteKey writes a DER encoded private key to file using output.Default,
+// existing keys are not overwritten.
 func WriteKey(key []byte, file string) error {
-	return writeFile(pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: key,
-	}), file)
-}
-
-func writeFile(buf []byte, file string) error {
-	fp, err := os.Create(file)
-	if err != nil {
-		return err
-	}
-	defer fp.Close()
-
-	_, err = fp.Write(buf)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return output.Default.WriteKey(key, file)
 }
@@ -2,10 +2,13 @@ package ovpncfg
 
 import (
 	"encoding/pem"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xiam/openvpn-config-generator/lib/certtool"
+	"github.com/xiam/openvpn-config-generator/lib/generator"
+	"github.com/xiam/openvpn-config-generator/lib/output"
 )
 
 var dhParameters = []byte(`-----BEGIN DH PARAMETERS-----
@@ -135,7 +138,7 @@ e6adb40fca5c5463
 	assert.NoError(t, err)
 	assert.NotEmpty(t, buf)
 
-	err = WriteConfig(config, "server.conf")
+	err = output.New(output.NewMemFS()).WriteConfig(config, "server.conf")
 	assert.NoError(t, err)
 }
 
@@ -259,7 +262,7 @@ e6adb40fca5c5463
 	assert.NoError(t, err)
 	assert.NotEmpty(t, buf)
 
-	err = WriteConfig(config, "client.conf")
+	err = output.New(output.NewMemFS()).WriteConfig(config, "client.conf")
 	assert.NoError(t, err)
 }
 
@@ -279,12 +282,14 @@ func TestGenCertificates(t *testing.T) {
 	clientCert, clientKey, err := certtool.BuildClientCertificate(caCert, caKey, "client.local")
 	assert.NoError(t, err)
 
-	WriteCert(caCert, "ca.crt")
-	WriteCert(serverCert, "server.crt")
-	WriteCert(clientCert, "client.crt")
+	out := output.New(output.NewMemFS())
 
-	WriteKey(serverKey, "server.key")
-	WriteKey(clientKey, "client.key")
+	assert.NoError(t, out.WriteCert(caCert, "ca.crt"))
+	assert.NoError(t, out.WriteCert(serverCert, "server.crt"))
+	assert.NoError(t, out.WriteCert(clientCert, "client.crt"))
+
+	assert.NoError(t, out.WriteKey(serverKey, "server.key"))
+	assert.NoError(t, out.WriteKey(clientKey, "client.key"))
 }
 
 func TestAutomaticConfig(t *testing.T) {
@@ -300,6 +305,9 @@ func TestAutomaticConfig(t *testing.T) {
 	vpnStaticKey, err := GenOpenVPNStaticKey()
 	assert.NoError(t, err)
 
+	fs := output.NewMemFS()
+	out := output.New(fs)
+
 	{
 		config, err := NewServerConfig()
 		assert.NoError(t, err)
@@ -313,7 +321,7 @@ func TestAutomaticConfig(t *testing.T) {
 
 		config.Embed("tls-crypt", vpnStaticKey)
 
-		err = WriteConfig(config, "server-auto.conf")
+		err = out.WriteConfig(config, "server-auto.conf")
 		assert.NoError(t, err)
 	}
 
@@ -328,7 +336,126 @@ func TestAutomaticConfig(t *testing.T) {
 
 		config.Embed("tls-crypt", vpnStaticKey)
 
-		err = WriteConfig(config, "client-auto.conf")
+		err = out.WriteConfig(config, "client-auto.conf")
+		assert.NoError(t, err)
+	}
+
+	// Both configurations embed private keys.
+	for _, name := range fs.Names() {
+		file, _ := fs.Open(name)
+		assert.Equal(t, output.PrivatePerm, file.Perm, name)
+	}
+}
+
+func TestSetManagement(t *testing.T) {
+	config, err := NewServerConfig()
+	assert.NoError(t, err)
+
+	assert.NoError(t, SetManagement(config, "unix:/run/openvpn/server.sock", ""))
+	values, _ := config.Get("management")
+	assert.Equal(t, []string{"/run/openvpn/server.sock", "unix"}, values)
+
+	assert.NoError(t, SetManagement(config, "127.0.0.1:7505", "/etc/openvpn/management.pw"))
+	values, _ = config.Get("management")
+	assert.Equal(t, []string{"127.0.0.1", "7505", "/etc/openvpn/management.pw"}, values)
+
+	assert.Error(t, SetManagement(config, "127.0.0.1:7505", ""))
+	assert.Error(t, SetManagement(config, "0.0.0.0:7505", "/etc/openvpn/management.pw"))
+
+	for address, expected := range map[string][2]string{
+		"unix:/run/openvpn/server.sock": {"unix", "/run/openvpn/server.sock"},
+		"/run/openvpn/server.sock":      {"unix", "/run/openvpn/server.sock"},
+		"127.0.0.1:7505":                {"tcp", "127.0.0.1:7505"},
+	} {
+		network, addr := ManagementAddress(address)
+		assert.Equal(t, expected, [2]string{network, addr})
+	}
+}
+
+func TestServerNetworks(t *testing.T) {
+	config, err := NewServerConfig()
+	assert.NoError(t, err)
+
+	networks, err := ServerNetworks(config)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(networks))
+	assert.Equal(t, "10.9.0.0/16", networks[0].String())
+
+	config.MustSet("server-ipv6", "fd00:9::/64")
+	networks, err = ServerNetworks(config)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(networks))
+	assert.Equal(t, "fd00:9::/64", networks[1].String())
+
+	config.MustSet("server", "10.9.0.0", "nope")
+	_, err = ServerNetworks(config)
+	assert.Error(t, err)
+
+	_, err = ServerNetworks(generator.New())
+	assert.Error(t, err)
+}
+
+func TestAdaptClientConfig(t *testing.T) {
+	_, err := ParseClientType("windows-phone")
+	assert.Error(t, err)
+
+	clientType, err := ParseClientType("OpenWrt")
+	assert.NoError(t, err)
+	assert.Equal(t, ClientOpenWrt, clientType)
+
+	{
+		config, err := NewClientConfig()
+		assert.NoError(t, err)
+
+		warnings, err := AdaptClientConfig(config, ClientGeneric)
+		assert.NoError(t, err)
+		assert.Empty(t, warnings)
+		assert.True(t, config.Has("keysize"))
+	}
+
+	{
+		config, err := NewClientConfig()
+		assert.NoError(t, err)
+		config.MustEmbed("pkcs12", []byte("MIIS"))
+		config.MustEnable("auth-user-pass")
+
+		warnings, err := AdaptClientConfig(config, ClientOpenWrt)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(warnings))
+		assert.False(t, config.Has("keysize"))
+
+		user, _ := config.Get("user")
+		assert.Equal(t, []string{"nobody"}, user)
+	}
+
+	{
+		config, err := NewClientConfig()
+		assert.NoError(t, err)
+		config.MustEmbed("auth-user-pass", []byte("alice\nsecret"))
+
+		warnings, err := AdaptClientConfig(config, ClientOpenWrt)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(warnings))
+	}
+
+	for _, clientType := range []ClientType{ClientOpenVPNConnect, ClientNetworkManager} {
+		config, err := NewClientConfig()
+		assert.NoError(t, err)
+		config.MustEmbed("auth-user-pass", []byte("alice\nsecret"))
+
+		warnings, err := AdaptClientConfig(config, clientType)
+		assert.NoError(t, err)
+		assert.Contains(t, strings.Join(warnings, "\n"), "auth-user-pass: ")
+	}
+
+	{
+		config, err := NewClientConfig()
+		assert.NoError(t, err)
+		config.MustSet("user", "nobody")
+
+		warnings, err := AdaptClientConfig(config, ClientTunnelblick)
 		assert.NoError(t, err)
+		assert.Equal(t, 1, len(warnings))
+		assert.False(t, config.Has("user"))
 	}
 }